@@ -1,6 +1,6 @@
 module github.com/algotuners/zerodha-sdk-go
 
-go 1.20
+go 1.21
 
 require (
 	github.com/gocarina/gocsv v0.0.0-20230616125104-99d496ca653d
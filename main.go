@@ -62,7 +62,13 @@ func onOrderUpdate(order kiteconnect.Order) {
 
 func main() {
 	//tokens := []string{"NSE:NIFTY BANK", "NSE:INFY"}
-	//kiteConnect := kiteticker.KiteConnect("", "kitefront")
+	//kiteConnect, err := kiteticker.KiteConnect(
+	//	kiteticker.WithBackend(kiteticker.BackendWeb),
+	//	kiteticker.WithAPIKey("kitefront"),
+	//)
+	//if err != nil {
+	//	panic(err.Error())
+	//}
 	//quote, err := kiteConnect.GetQuote(tokens...)
 	//println(quote["NSE:NIFTY BANK"].InstrumentToken)
 	//println(quote["NSE:INFY"].InstrumentToken)
@@ -74,12 +80,24 @@ func main() {
 	//	marketDepth[token] = quote[token].Depth
 	//}
 
-	//kc := pkg.KiteConnect(
-	//	"",
-	//	"kitefront",
+	//kc, err := kiteticker.KiteConnect(
+	//	kiteticker.WithBackend(kiteticker.BackendWeb),
+	//	kiteticker.WithAPIKey("kitefront"),
+	//	kiteticker.WithEncToken(""),
 	//)
+	//if err != nil {
+	//	panic(err.Error())
+	//}
 	//
-	//ticker = kiteticker.KiteTicker("kitefront", kc.GetEncToken())
+	//ticker, err = kiteticker.KiteTicker(
+	//	kiteticker.WithTickerBackend(kiteticker.BackendWeb),
+	//	kiteticker.WithTickerUserID(getEnv("KITE_USER_ID", "")),
+	//	kiteticker.WithTickerAPIKey("kitefront"),
+	//	kiteticker.WithTickerEncToken(kc.GetEncToken()),
+	//)
+	//if err != nil {
+	//	panic(err.Error())
+	//}
 	//
 	//// Assign callbacks
 	//ticker.OnError(onError)
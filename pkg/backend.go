@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"net/url"
+)
+
+// Backend selects the Kite deployment requests and ticker connections are
+// made to. The API is identical on both, they differ in their hosts and how
+// sessions authenticate.
+type Backend int
+
+const (
+	// BackendConnect is the Kite Connect API at api.kite.trade, authenticated
+	// with an API key and access token.
+	BackendConnect Backend = iota
+	// BackendWeb is the OMS behind the Kite web app at kite.zerodha.com/oms,
+	// authenticated with the enctoken of a web session. Its ticker also
+	// requires the user id.
+	BackendWeb
+)
+
+func (backend Backend) String() string {
+	switch backend {
+	case BackendConnect:
+		return "connect"
+	case BackendWeb:
+		return "web"
+	}
+	return fmt.Sprintf("Backend(%d)", int(backend))
+}
+
+func (backend Backend) valid() bool {
+	return backend == BackendConnect || backend == BackendWeb
+}
+
+func (backend Backend) baseURI() string {
+	if backend == BackendWeb {
+		return constants.WebBaseURI
+	}
+	return constants.BaseURI
+}
+
+func (backend Backend) tickerURL() url.URL {
+	tickerURI := constants.TickerURI
+	if backend == BackendWeb {
+		tickerURI = constants.WebTickerURI
+	}
+	u, _ := url.Parse(tickerURI)
+	return *u
+}
+
+// authMode is the auth mode AuthModeAuto resolves to.
+func (backend Backend) authMode() AuthMode {
+	if backend == BackendWeb {
+		return AuthModeEncToken
+	}
+	return AuthModeAccessToken
+}
+
+// SetBackend points the client at the backend's base URI and, unless an auth
+// mode is set explicitly, authenticates as the backend expects.
+func (kiteHttpClient *KiteHttpClient) SetBackend(backend Backend) {
+	kiteHttpClient.backend = backend
+	kiteHttpClient.SetBaseURI(backend.baseURI())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetBackend() Backend {
+	return kiteHttpClient.backend
+}
+
+// SetBackend points the ticker at the backend's root URL and selects the
+// credentials sent when connecting.
+func (t *Ticker) SetBackend(backend Backend) {
+	t.mu.Lock()
+	t.backend = backend
+	t.url = backend.tickerURL()
+	t.mu.Unlock()
+}
+
+// validate checks the ticker holds the credentials its backend connects with.
+// Credentials may still be set later, e.g. by a token refresh.
+func (t *Ticker) validate() error {
+	if t.backend == BackendWeb {
+		if t.userID == "" {
+			return errors.New("the web backend ticker requires a user id")
+		}
+		return nil
+	}
+	if t.accessToken == "" && t.encToken != "" {
+		return errors.New("the connect backend ticker requires an access token, use the web backend to connect with an enctoken")
+	}
+	if t.accessToken != "" && t.apiKey == "" {
+		return errors.New("the connect backend ticker requires an API key")
+	}
+	return nil
+}
+
+// connectQuery returns the query authenticating a ticker connection. t.mu
+// must be held.
+func (t *Ticker) connectQuery() url.Values {
+	q := t.url.Query()
+	q.Set("api_key", t.apiKey)
+	if t.backend == BackendWeb {
+		q.Set("user_id", t.userID)
+		q.Set("enctoken", t.encToken)
+	} else {
+		q.Set("access_token", t.accessToken)
+	}
+	return q
+}
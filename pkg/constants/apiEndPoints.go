@@ -4,9 +4,14 @@ const (
 	URIUserSession           string = "/session/token"
 	URIUserSessionInvalidate string = "/session/token"
 	URIUserSessionRenew      string = "/session/refresh_token"
-	URIUserProfile           string = "/user/profile"
-	URIUserMargins           string = "/user/margins"
-	URIUserMarginsSegment    string = "/user/margins/%s" // "/user/margins/{segment}"
+
+	// Kite web login endpoints, relative to KiteBaseURI.
+	URIWebLogin string = "/api/login"
+	URIWebTwoFA string = "/api/twofa"
+
+	URIUserProfile        string = "/user/profile"
+	URIUserMargins        string = "/user/margins"
+	URIUserMarginsSegment string = "/user/margins/%s" // "/user/margins/{segment}"
 
 	URIGetOrders       string = "/orders"
 	URIGetTrades       string = "/trades"
@@ -31,6 +31,16 @@ const (
 	PositionTypeDay       = "day"
 	PositionTypeOvernight = "overnight"
 
+	// Holdings authorisation types
+	HolAuthTypeMF     = "mf"
+	HolAuthTypeEquity = "equity"
+
+	// Holdings authorisation transfer types
+	HolAuthTransferTypePreTrade  = "pre"
+	HolAuthTransferTypePostTrade = "post"
+	HolAuthTransferTypeOffMarket = "off"
+	HolAuthTransferTypeGift      = "gift"
+
 	// Transaction type
 	TransactionTypeBuy  = "BUY"
 	TransactionTypeSell = "SELL"
@@ -48,6 +58,15 @@ const (
 	MarginsEquity    = "equity"
 	MarginsCommodity = "commodity"
 
+	// MF SIP frequencies
+	MFSIPFrequencyWeekly    = "weekly"
+	MFSIPFrequencyMonthly   = "monthly"
+	MFSIPFrequencyQuarterly = "quarterly"
+
+	// MF SIP statuses
+	MFSIPStatusActive = "active"
+	MFSIPStatusPaused = "paused"
+
 	// Order status
 	OrderStatusComplete  = "COMPLETE"
 	OrderStatusRejected  = "REJECTED"
@@ -7,6 +7,9 @@ const (
 	Version           string        = "4.0.2"
 	RequestTimeout    time.Duration = 7000 * time.Millisecond
 	BaseURI           string        = "https://api.kite.trade"
+	WebBaseURI        string        = "https://kite.zerodha.com/oms"
 	KiteBaseURI       string        = "https://kite.zerodha.com"
+	TickerURI         string        = "wss://ws.kite.trade"
+	WebTickerURI      string        = "wss://ws.zerodha.com"
 	KiteHeaderVersion string        = "3"
 )
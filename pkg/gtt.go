@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/models"
+	"net/http"
+	"net/url"
+)
+
+// GTTType represents the type of a GTT trigger.
+type GTTType string
+
+const (
+	// GTTTypeSingle is a single-leg trigger.
+	GTTTypeSingle GTTType = "single"
+	// GTTTypeOCO is a two-leg one-cancels-other trigger.
+	GTTTypeOCO GTTType = "two-leg"
+)
+
+type GTTMeta struct {
+	RejectionReason string `json:"rejection_reason"`
+}
+
+type GTTCondition struct {
+	Exchange      string    `json:"exchange"`
+	Tradingsymbol string    `json:"tradingsymbol"`
+	LastPrice     float64   `json:"last_price"`
+	TriggerValues []float64 `json:"trigger_values"`
+}
+
+type GTT struct {
+	ID        int          `json:"id"`
+	UserID    string       `json:"user_id"`
+	Type      GTTType      `json:"type"`
+	CreatedAt models.Time  `json:"created_at"`
+	UpdatedAt models.Time  `json:"updated_at"`
+	ExpiresAt models.Time  `json:"expires_at"`
+	Status    string       `json:"status"`
+	Condition GTTCondition `json:"condition"`
+	Orders    []Order      `json:"orders"`
+	Meta      GTTMeta      `json:"meta"`
+}
+
+type GTTs []GTT
+
+// Trigger is implemented by the single-leg and two-leg GTT triggers. All the
+// slices are ordered by leg.
+type Trigger interface {
+	TriggerValues() []float64
+	LimitPrices() []float64
+	Quantities() []float64
+	Type() GTTType
+}
+
+// TriggerParams describes a single leg of a GTT: the price at which it fires
+// and the limit order placed when it does.
+type TriggerParams struct {
+	TriggerValue float64
+	LimitPrice   float64
+	Quantity     float64
+}
+
+type GTTSingleLegTrigger struct {
+	TriggerParams
+}
+
+func (t GTTSingleLegTrigger) TriggerValues() []float64 {
+	return []float64{t.TriggerValue}
+}
+
+func (t GTTSingleLegTrigger) LimitPrices() []float64 {
+	return []float64{t.LimitPrice}
+}
+
+func (t GTTSingleLegTrigger) Quantities() []float64 {
+	return []float64{t.Quantity}
+}
+
+func (t GTTSingleLegTrigger) Type() GTTType {
+	return GTTTypeSingle
+}
+
+// GTTOneCancelsOtherTrigger places the order of whichever leg is hit first
+// and cancels the other. Lower is usually the stoploss and Upper the target.
+type GTTOneCancelsOtherTrigger struct {
+	Upper TriggerParams
+	Lower TriggerParams
+}
+
+func (t GTTOneCancelsOtherTrigger) TriggerValues() []float64 {
+	return []float64{t.Lower.TriggerValue, t.Upper.TriggerValue}
+}
+
+func (t GTTOneCancelsOtherTrigger) LimitPrices() []float64 {
+	return []float64{t.Lower.LimitPrice, t.Upper.LimitPrice}
+}
+
+func (t GTTOneCancelsOtherTrigger) Quantities() []float64 {
+	return []float64{t.Lower.Quantity, t.Upper.Quantity}
+}
+
+func (t GTTOneCancelsOtherTrigger) Type() GTTType {
+	return GTTTypeOCO
+}
+
+type GTTParams struct {
+	Tradingsymbol   string
+	Exchange        string
+	LastPrice       float64
+	TransactionType string
+	Product         string
+	Trigger         Trigger
+}
+
+type GTTResponse struct {
+	TriggerID int `json:"trigger_id"`
+}
+
+type gttOrder struct {
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	Quantity        float64 `json:"quantity"`
+	Price           float64 `json:"price"`
+	OrderType       string  `json:"order_type"`
+	Product         string  `json:"product"`
+}
+
+// validate checks that every leg of the trigger has a positive trigger value,
+// limit price and quantity and that a two-leg trigger straddles the last
+// price, which the API otherwise rejects.
+func (gttParams GTTParams) validate() error {
+	required := httpUtils.NewErrorHelper(httpUtils.InputError, "GTT trigger is required", nil)
+
+	var oco *GTTOneCancelsOtherTrigger
+	switch trigger := gttParams.Trigger.(type) {
+	case nil:
+		return required
+	case *GTTSingleLegTrigger:
+		if trigger == nil {
+			return required
+		}
+	case GTTOneCancelsOtherTrigger:
+		oco = &trigger
+	case *GTTOneCancelsOtherTrigger:
+		if trigger == nil {
+			return required
+		}
+		oco = trigger
+	}
+
+	var (
+		triggerValues = gttParams.Trigger.TriggerValues()
+		limitPrices   = gttParams.Trigger.LimitPrices()
+		quantities    = gttParams.Trigger.Quantities()
+	)
+	if len(triggerValues) == 0 || len(limitPrices) != len(triggerValues) || len(quantities) != len(triggerValues) {
+		return httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("GTT trigger has %d trigger values, %d limit prices and %d quantities, want one of each per leg", len(triggerValues), len(limitPrices), len(quantities)), nil)
+	}
+	for i := range triggerValues {
+		if triggerValues[i] <= 0 || limitPrices[i] <= 0 || quantities[i] <= 0 {
+			return httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("GTT leg %d needs a positive trigger value, limit price and quantity, got %v, %v and %v", i+1, triggerValues[i], limitPrices[i], quantities[i]), nil)
+		}
+	}
+
+	if oco != nil && (oco.Lower.TriggerValue >= gttParams.LastPrice || oco.Upper.TriggerValue <= gttParams.LastPrice) {
+		return httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Two-leg trigger values %v must straddle last price %v", oco.TriggerValues(), gttParams.LastPrice), nil)
+	}
+
+	return nil
+}
+
+// values encodes the condition and the orders as the JSON form fields the
+// GTT endpoints expect.
+func (gttParams GTTParams) values() (url.Values, error) {
+	if err := gttParams.validate(); err != nil {
+		return nil, err
+	}
+
+	condition, err := json.Marshal(GTTCondition{
+		Exchange:      gttParams.Exchange,
+		Tradingsymbol: gttParams.Tradingsymbol,
+		LastPrice:     gttParams.LastPrice,
+		TriggerValues: gttParams.Trigger.TriggerValues(),
+	})
+	if err != nil {
+		return nil, httpUtils.WrapError(httpUtils.InputError, "Error encoding GTT condition.", 0, err)
+	}
+
+	var (
+		limitPrices = gttParams.Trigger.LimitPrices()
+		quantities  = gttParams.Trigger.Quantities()
+		orders      []gttOrder
+	)
+	for i := range limitPrices {
+		orders = append(orders, gttOrder{
+			Exchange:        gttParams.Exchange,
+			Tradingsymbol:   gttParams.Tradingsymbol,
+			TransactionType: gttParams.TransactionType,
+			Quantity:        quantities[i],
+			Price:           limitPrices[i],
+			OrderType:       constants.OrderTypeLimit,
+			Product:         gttParams.Product,
+		})
+	}
+
+	ordersJSON, err := json.Marshal(orders)
+	if err != nil {
+		return nil, httpUtils.WrapError(httpUtils.InputError, "Error encoding GTT orders.", 0, err)
+	}
+
+	params := url.Values{}
+	params.Set("type", string(gttParams.Trigger.Type()))
+	params.Set("condition", string(condition))
+	params.Set("orders", string(ordersJSON))
+	return params, nil
+}
+
+func (kiteHttpClient *KiteHttpClient) GetGTTs() (GTTs, error) {
+	return kiteHttpClient.GetGTTsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetGTTsWithContext(ctx context.Context) (GTTs, error) {
+	var gtts GTTs
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetGTTs, nil, nil, &gtts)
+	return gtts, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetGTT(triggerID int) (GTT, error) {
+	return kiteHttpClient.GetGTTWithContext(context.Background(), triggerID)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetGTTWithContext(ctx context.Context, triggerID int) (GTT, error) {
+	var gtt GTT
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetGTT, triggerID), nil, nil, &gtt)
+	return gtt, err
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceGTT(gttParams GTTParams) (GTTResponse, error) {
+	return kiteHttpClient.PlaceGTTWithContext(context.Background(), gttParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceGTTWithContext(ctx context.Context, gttParams GTTParams) (GTTResponse, error) {
+	var gttResponse GTTResponse
+
+	params, err := gttParams.values()
+	if err != nil {
+		return gttResponse, err
+	}
+
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIPlaceGTT, params, nil, &gttResponse)
+	return gttResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) ModifyGTT(triggerID int, gttParams GTTParams) (GTTResponse, error) {
+	return kiteHttpClient.ModifyGTTWithContext(context.Background(), triggerID, gttParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) ModifyGTTWithContext(ctx context.Context, triggerID int, gttParams GTTParams) (GTTResponse, error) {
+	var gttResponse GTTResponse
+
+	params, err := gttParams.values()
+	if err != nil {
+		return gttResponse, err
+	}
+
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(constants.URIModifyGTT, triggerID), params, nil, &gttResponse)
+	return gttResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) DeleteGTT(triggerID int) (GTTResponse, error) {
+	return kiteHttpClient.DeleteGTTWithContext(context.Background(), triggerID)
+}
+
+func (kiteHttpClient *KiteHttpClient) DeleteGTTWithContext(ctx context.Context, triggerID int) (GTTResponse, error) {
+	var gttResponse GTTResponse
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(constants.URIDeleteGTT, triggerID), nil, nil, &gttResponse)
+	return gttResponse, err
+}
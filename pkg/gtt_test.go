@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+)
+
+func TestGTTParamsValidate(t *testing.T) {
+	var (
+		lower  = TriggerParams{TriggerValue: 90, LimitPrice: 89, Quantity: 1}
+		upper  = TriggerParams{TriggerValue: 110, LimitPrice: 111, Quantity: 1}
+		single = GTTSingleLegTrigger{TriggerParams: lower}
+		oco    = GTTOneCancelsOtherTrigger{Lower: lower, Upper: upper}
+
+		inverted   = GTTOneCancelsOtherTrigger{Lower: upper, Upper: lower}
+		noQuantity = GTTOneCancelsOtherTrigger{Lower: lower, Upper: TriggerParams{TriggerValue: 110, LimitPrice: 111}}
+	)
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		wantErr bool
+	}{
+		{"single", single, false},
+		{"single pointer", &single, false},
+		{"two-leg", oco, false},
+		{"two-leg pointer", &oco, false},
+
+		{"no trigger", nil, true},
+		{"nil single pointer", (*GTTSingleLegTrigger)(nil), true},
+		{"nil two-leg pointer", (*GTTOneCancelsOtherTrigger)(nil), true},
+		{"single without trigger value", GTTSingleLegTrigger{TriggerParams{LimitPrice: 89, Quantity: 1}}, true},
+		{"single without limit price", &GTTSingleLegTrigger{TriggerParams{TriggerValue: 90, Quantity: 1}}, true},
+		{"single without quantity", GTTSingleLegTrigger{TriggerParams{TriggerValue: 90, LimitPrice: 89}}, true},
+		{"single with negative quantity", GTTSingleLegTrigger{TriggerParams{TriggerValue: 90, LimitPrice: 89, Quantity: -1}}, true},
+		{"two-leg not straddling", inverted, true},
+		{"two-leg pointer not straddling", &inverted, true},
+		{"two-leg leg without quantity", noQuantity, true},
+		{"two-leg pointer leg without quantity", &noQuantity, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gttParams := GTTParams{Tradingsymbol: "INFY", Exchange: "NSE", LastPrice: 100, Trigger: tt.trigger}
+			err := gttParams.validate()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validate() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, httpUtils.ErrInput) {
+				t.Errorf("validate() error = %v, want %v", err, httpUtils.ErrInput)
+			}
+		})
+	}
+}
@@ -1,60 +1,123 @@
 package httpUtils
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+)
 
 const (
 	GeneralError    = "GeneralException"
 	TokenError      = "TokenException"
-	PermissionError = "PermissionError"
+	PermissionError = "PermissionException"
 	UserError       = "UserException"
 	TwoFAError      = "TwoFAException"
 	OrderError      = "OrderException"
 	InputError      = "InputException"
 	DataError       = "DataException"
 	NetworkError    = "NetworkException"
+	MarginError     = "MarginException"
+	HoldingError    = "HoldingException"
+
+	// RateLimitError is raised client side when a request is refused for
+	// exceeding a configured limit.
+	RateLimitError = "RateLimitException"
+)
+
+// Sentinel errors matched by Error through errors.Is, one per error type.
+// Orders refused for lack of margin or holdings match ErrOrderRejected and
+// unknown types ErrGeneral. HTTP 429 responses match ErrRateLimited only,
+// whatever their error type.
+var (
+	ErrGeneral       = errors.New("kite: general error")
+	ErrTokenExpired  = errors.New("kite: token expired or invalid")
+	ErrPermission    = errors.New("kite: permission denied")
+	ErrUser          = errors.New("kite: user account error")
+	ErrTwoFA         = errors.New("kite: two-factor authentication failed")
+	ErrOrderRejected = errors.New("kite: order rejected")
+	ErrInput         = errors.New("kite: invalid input")
+	ErrData          = errors.New("kite: unexpected response data")
+	ErrNetwork       = errors.New("kite: network error")
+	ErrRateLimited   = errors.New("kite: rate limited")
 )
 
+var sentinels = map[string]error{
+	GeneralError:    ErrGeneral,
+	TokenError:      ErrTokenExpired,
+	PermissionError: ErrPermission,
+	UserError:       ErrUser,
+	TwoFAError:      ErrTwoFA,
+	OrderError:      ErrOrderRejected,
+	InputError:      ErrInput,
+	DataError:       ErrData,
+	NetworkError:    ErrNetwork,
+	MarginError:     ErrOrderRejected,
+	HoldingError:    ErrOrderRejected,
+	RateLimitError:  ErrRateLimited,
+
+	// PermissionError held this value in earlier releases.
+	"PermissionError": ErrPermission,
+}
+
+// Error is the error returned for every failed request. Code is the HTTP
+// status of the response, or 0 when no response was received or the error
+// was raised client side, and Header the response headers. Err is the
+// underlying cause, if any.
 type Error struct {
 	Code      int
 	ErrorType string
 	Message   string
 	Data      interface{}
+	Header    http.Header
+	Err       error
 }
 
 func (e Error) Error() string {
+	if e.Err != nil {
+		return e.Message + " (" + e.Err.Error() + ")"
+	}
 	return e.Message
 }
 
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel for the error type.
+func (e Error) Is(target error) bool {
+	if e.Code == http.StatusTooManyRequests {
+		return target == ErrRateLimited
+	}
+	if sentinel, ok := sentinels[e.ErrorType]; ok {
+		return sentinel == target
+	}
+	return target == ErrGeneral
+}
+
+// Retryable reports whether the request may succeed if sent again: its
+// response shows it was throttled or hit a gateway error, or it failed in
+// transport before a complete response was read. Errors raised client side
+// aren't retryable.
+func (e Error) Retryable() bool {
+	switch e.Code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return e.ErrorType == NetworkError && e.Err != nil
+}
+
+// IsRetryable reports whether err is an Error that is Retryable.
+func IsRetryable(err error) bool {
+	var e Error
+	return errors.As(err, &e) && e.Retryable()
+}
+
+// NewErrorHelper returns an Error of the given type raised client side. No
+// response was received, so its Code is 0. Unknown types are GeneralError.
 func NewErrorHelper(etype string, message string, data interface{}) error {
-	var (
-		code = http.StatusInternalServerError
-	)
-
-	switch etype {
-	case GeneralError:
-		code = http.StatusInternalServerError
-	case TokenError:
-		code = http.StatusForbidden
-	case PermissionError:
-		code = http.StatusForbidden
-	case UserError:
-		code = http.StatusForbidden
-	case TwoFAError:
-		code = http.StatusForbidden
-	case OrderError:
-		code = http.StatusBadRequest
-	case InputError:
-		code = http.StatusBadRequest
-	case DataError:
-		code = http.StatusGatewayTimeout
-	case NetworkError:
-		code = http.StatusServiceUnavailable
-	default:
-		code = http.StatusInternalServerError
+	if _, ok := sentinels[etype]; !ok {
 		etype = GeneralError
 	}
-
-	return NewError(etype, message, code, data)
+	return NewError(etype, message, 0, data)
 }
 
 func NewError(etype, message string, code int, data interface{}) Error {
@@ -66,6 +129,16 @@ func NewError(etype, message string, code int, data interface{}) Error {
 	}
 }
 
+// WrapError returns an Error of the given type caused by err.
+func WrapError(etype, message string, code int, err error) Error {
+	return Error{
+		Message:   message,
+		ErrorType: etype,
+		Code:      code,
+		Err:       err,
+	}
+}
+
 func GetErrorName(code int) string {
 	var err string
 
@@ -76,7 +149,9 @@ func GetErrorName(code int) string {
 		err = TokenError
 	case http.StatusBadRequest:
 		err = InputError
-	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	case http.StatusTooManyRequests:
+		err = RateLimitError
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
 		err = NetworkError
 	default:
 		err = GeneralError
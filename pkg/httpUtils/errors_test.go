@@ -0,0 +1,70 @@
+package httpUtils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestLocalErrors(t *testing.T) {
+	err := NewErrorHelper(DataError, "Error decoding candle", nil)
+	var e Error
+	if !errors.As(err, &e) || e.Code != 0 {
+		t.Errorf("NewErrorHelper() = %#v, want Code 0", err)
+	}
+	if IsRetryable(err) {
+		t.Error("client side error is retryable")
+	}
+	if !errors.Is(err, ErrData) {
+		t.Error("client side error doesn't match its sentinel")
+	}
+
+	cause := json.Unmarshal([]byte("{"), &struct{}{})
+	err = WrapError(InputError, "Error decoding order params.", 0, cause)
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("WrapError() = %v doesn't reach its cause", err)
+	}
+	if IsRetryable(err) {
+		t.Error("wrapped client side error is retryable")
+	}
+}
+
+func TestResponseErrors(t *testing.T) {
+	response := func(code int, body string) HTTPResponse {
+		return HTTPResponse{Body: []byte(body), Response: &http.Response{StatusCode: code, Header: http.Header{}}}
+	}
+
+	tests := []struct {
+		name      string
+		resp      HTTPResponse
+		sentinel  error
+		notMatch  error
+		retryable bool
+	}{
+		{"rate limited", response(http.StatusTooManyRequests, `{"status":"error","error_type":"NetworkException","message":"Too many requests"}`), ErrRateLimited, ErrNetwork, true},
+		{"rate limited by a gateway", response(http.StatusTooManyRequests, "Too many requests"), ErrRateLimited, ErrNetwork, true},
+		{"unavailable", response(http.StatusServiceUnavailable, "<html>"), ErrNetwork, ErrRateLimited, true},
+		{"token", response(http.StatusForbidden, `{"status":"error","error_type":"TokenException","message":"Invalid session"}`), ErrTokenExpired, ErrNetwork, false},
+		{"permission", response(http.StatusForbidden, `{"status":"error","error_type":"PermissionException","message":"Insufficient permission"}`), ErrPermission, ErrTokenExpired, false},
+		{"margin", response(http.StatusBadRequest, `{"status":"error","error_type":"MarginException","message":"Insufficient funds"}`), ErrOrderRejected, ErrGeneral, false},
+		{"holding", response(http.StatusBadRequest, `{"status":"error","error_type":"HoldingException","message":"Insufficient holdings"}`), ErrOrderRejected, ErrGeneral, false},
+		{"unknown type", response(http.StatusBadRequest, `{"status":"error","error_type":"SomeNewException","message":"Something"}`), ErrGeneral, ErrInput, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadEnvelope(tt.resp, nil)
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("error %v doesn't match %v", err, tt.sentinel)
+			}
+			if errors.Is(err, tt.notMatch) {
+				t.Errorf("error %v matches %v", err, tt.notMatch)
+			}
+			if IsRetryable(err) != tt.retryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, !tt.retryable, tt.retryable)
+			}
+		})
+	}
+}
@@ -2,20 +2,28 @@ package httpUtils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/logging"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 )
 
+// debugBodyLimit is the number of bytes of request and response bodies
+// logged in debug mode.
+const debugBodyLimit = 4096
+
 type BaseHttpClient struct {
-	Client  *http.Client
-	httpLog *log.Logger
-	Debug   bool
+	Client      *http.Client
+	logger      *slog.Logger
+	Debug       bool
+	middlewares []Middleware
 }
 
 type HTTPResponse struct {
@@ -24,7 +32,6 @@ type HTTPResponse struct {
 }
 
 func GenerateHttpClient(httpClient *http.Client, debug bool) HTTPClient {
-	httpLog := log.New(os.Stdout, "base.HTTP: ", log.Ldate|log.Ltime|log.Lshortfile)
 	if httpClient == nil {
 		httpClient = &http.Client{
 			Timeout: time.Duration(5) * time.Second,
@@ -35,20 +42,20 @@ func GenerateHttpClient(httpClient *http.Client, debug bool) HTTPClient {
 		}
 	}
 	return &BaseHttpClient{
-		httpLog: httpLog,
-		Client:  httpClient,
-		Debug:   debug,
+		logger: logging.New(nil),
+		Client: httpClient,
+		Debug:  debug,
 	}
 }
 
-func (baseHttpClient *BaseHttpClient) Do(method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error) {
+func (baseHttpClient *BaseHttpClient) Do(ctx context.Context, method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error) {
 	if params == nil {
 		params = url.Values{}
 	}
-	return baseHttpClient.DoRaw(method, rURL, []byte(params.Encode()), headers)
+	return baseHttpClient.DoRaw(ctx, method, rURL, []byte(params.Encode()), headers)
 }
 
-func (baseHttpClient *BaseHttpClient) DoRaw(method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
+func (baseHttpClient *BaseHttpClient) DoRaw(ctx context.Context, method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error) {
 	var (
 		httpResponse = HTTPResponse{}
 		err          error
@@ -59,14 +66,14 @@ func (baseHttpClient *BaseHttpClient) DoRaw(method, rURL string, reqBody []byte,
 		postBody = bytes.NewReader(reqBody)
 	}
 
-	req, err := http.NewRequest(method, rURL, postBody)
+	req, err := http.NewRequestWithContext(ctx, method, rURL, postBody)
 	if err != nil {
-		baseHttpClient.httpLog.Printf("Request preparation failed: %v", err)
-		return httpResponse, NewErrorHelper(NetworkError, "Request preparation failed.", nil)
+		baseHttpClient.logger.Error("Request preparation failed", "method", method, "error", err)
+		return httpResponse, WrapError(InputError, "Request preparation failed.", 0, err)
 	}
 
 	if headers != nil {
-		req.Header = headers
+		req.Header = headers.Clone()
 	}
 
 	if req.Header.Get("Content-Type") == "" {
@@ -79,74 +86,132 @@ func (baseHttpClient *BaseHttpClient) DoRaw(method, rURL string, reqBody []byte,
 		req.URL.RawQuery = string(reqBody)
 	}
 
-	clientResponse, err := baseHttpClient.Client.Do(req)
+	clientResponse, err := Chain(baseHttpClient.Client.Do, baseHttpClient.middlewares...)(req)
 	if err != nil {
-		baseHttpClient.httpLog.Printf("Request failed: %v", err)
-		return httpResponse, NewErrorHelper(NetworkError, "Request failed.", nil)
+		// Cancellation and deadlines are the caller's doing, not a network fault.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return httpResponse, ctxErr
+		}
+		baseHttpClient.logger.Warn("Request failed", "method", method, "path", req.URL.Path, "error", err)
+		return httpResponse, WrapError(NetworkError, "Request failed.", 0, err)
 	}
 	defer clientResponse.Body.Close()
 
 	body, err := ioutil.ReadAll(clientResponse.Body)
 	if err != nil {
-		baseHttpClient.httpLog.Printf("Unable to read response: %v", err)
-		return httpResponse, NewErrorHelper(DataError, "Error reading response.", nil)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return httpResponse, ctxErr
+		}
+		baseHttpClient.logger.Warn("Unable to read response", "method", method, "path", req.URL.Path, "status", clientResponse.StatusCode, "error", err)
+		e := WrapError(NetworkError, "Error reading response.", clientResponse.StatusCode, err)
+		e.Header = clientResponse.Header
+		return httpResponse, e
 	}
 
 	httpResponse.Response = clientResponse
 	httpResponse.Body = body
 	if baseHttpClient.Debug {
-		baseHttpClient.httpLog.Printf("%s %s -- %d %v", method, req.URL.RequestURI(), httpResponse.Response.StatusCode, req.Header)
+		// Log the request as sent, with the headers middlewares added.
+		sent := req
+		if clientResponse.Request != nil {
+			sent = clientResponse.Request
+		}
+		attrs := []any{"method", method, "uri", sent.URL.RequestURI(), "status", clientResponse.StatusCode, "headers", sent.Header}
+		if postBody != nil {
+			attrs = append(attrs, "request_body", debugBody(reqBody))
+		}
+		baseHttpClient.logger.Debug("Request completed", append(attrs, "response_body", debugBody(body))...)
 	}
 
 	return httpResponse, nil
 }
 
-func (baseHttpClient *BaseHttpClient) DoEnvelope(method, url string, params url.Values, headers http.Header, obj interface{}) error {
-	resp, err := baseHttpClient.Do(method, url, params, headers)
+func (baseHttpClient *BaseHttpClient) DoEnvelope(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) error {
+	resp, err := baseHttpClient.Do(ctx, method, url, params, headers)
 	if err != nil {
 		return err
 	}
 
-	err = readEnvelope(resp, obj)
-	if err != nil {
-		if _, ok := err.(Error); !ok {
-			baseHttpClient.httpLog.Printf("Error parsing JSON response: %v", err)
-		}
+	err = ReadEnvelope(resp, obj)
+	if errors.Is(err, ErrData) {
+		baseHttpClient.logger.Warn("Error parsing JSON response", "method", method, "error", err)
 	}
 
 	return err
 }
 
-func readEnvelope(resp HTTPResponse, obj interface{}) error {
-	if resp.Response.StatusCode >= http.StatusBadRequest {
+func ReadEnvelope(resp HTTPResponse, obj interface{}) error {
+	var (
+		code   = resp.Response.StatusCode
+		header = resp.Response.Header
+	)
+
+	if code >= http.StatusBadRequest {
 		var e HttpErrorEnvelope
 		if err := json.Unmarshal(resp.Body, &e); err != nil {
-			return NewErrorHelper(DataError, "Error parsing response.", nil)
+			// Gateways in front of the API answer with non-JSON bodies, classify
+			// those by their status instead.
+			wrapped := WrapError(GetErrorName(code), "Error parsing response.", code, err)
+			wrapped.Header = header
+			return wrapped
 		}
-		return NewError(e.ErrorType, e.Message, resp.Response.StatusCode, e.Data)
+		if e.ErrorType == "" {
+			e.ErrorType = GetErrorName(code)
+		}
+		kiteErr := NewError(e.ErrorType, e.Message, code, e.Data)
+		kiteErr.Header = header
+		return kiteErr
 	}
 	successEnvl := HttpSuccessEnvelope{}
 	successEnvl.Data = obj
 	if err := json.Unmarshal(resp.Body, &successEnvl); err != nil {
-		return NewErrorHelper(DataError, "Error parsing response.", nil)
+		wrapped := WrapError(DataError, "Error parsing response.", code, err)
+		wrapped.Header = header
+		return wrapped
 	}
 	return nil
 }
 
-func (baseHttpClient *BaseHttpClient) DoJSON(method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error) {
-	resp, err := baseHttpClient.Do(method, url, params, headers)
+func (baseHttpClient *BaseHttpClient) DoJSON(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error) {
+	resp, err := baseHttpClient.Do(ctx, method, url, params, headers)
 	if err != nil {
 		return resp, err
 	}
 
 	if err := json.Unmarshal(resp.Body, &obj); err != nil {
-		baseHttpClient.httpLog.Printf("Error parsing JSON response: %v | %s", err, resp.Body)
-		return resp, NewErrorHelper(DataError, "Error parsing response.", nil)
+		baseHttpClient.logger.Warn("Error parsing JSON response", "method", method, "error", err, "body", string(resp.Body))
+		wrapped := WrapError(DataError, "Error parsing response.", resp.Response.StatusCode, err)
+		wrapped.Header = resp.Response.Header
+		return resp, wrapped
 	}
 
 	return resp, nil
 }
 
-func (baseHttpClient *BaseHttpClient) GetClient() *BaseHttpClient {
-	return baseHttpClient
+// debugBody returns body for logging, truncated to debugBodyLimit bytes.
+func debugBody(body []byte) string {
+	if len(body) <= debugBodyLimit {
+		return string(body)
+	}
+	return fmt.Sprintf("%s... (%d bytes)", body[:debugBodyLimit], len(body))
+}
+
+// Use appends middlewares to the chain every request is sent through.
+func (baseHttpClient *BaseHttpClient) Use(middlewares ...Middleware) {
+	baseHttpClient.middlewares = append(baseHttpClient.middlewares, middlewares...)
+}
+
+// SetLogger sets the logger, wrapped so that secrets are redacted.
+func (baseHttpClient *BaseHttpClient) SetLogger(logger *slog.Logger) {
+	baseHttpClient.logger = logging.New(logger)
+}
+
+// SetDebug toggles debug logging of every completed request. See
+// KiteHttpClient.SetDebug.
+func (baseHttpClient *BaseHttpClient) SetDebug(debug bool) {
+	baseHttpClient.Debug = debug
+}
+
+func (baseHttpClient *BaseHttpClient) SetTimeout(timeout time.Duration) {
+	baseHttpClient.Client.Timeout = timeout
 }
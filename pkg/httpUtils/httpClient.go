@@ -1,14 +1,20 @@
 package httpUtils
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type HTTPClient interface {
-	Do(method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error)
-	DoRaw(method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error)
-	DoEnvelope(method, url string, params url.Values, headers http.Header, obj interface{}) error
-	DoJSON(method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error)
-	GetClient() *BaseHttpClient
+	Do(ctx context.Context, method, rURL string, params url.Values, headers http.Header) (HTTPResponse, error)
+	DoRaw(ctx context.Context, method, rURL string, reqBody []byte, headers http.Header) (HTTPResponse, error)
+	DoEnvelope(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) error
+	DoJSON(ctx context.Context, method, url string, params url.Values, headers http.Header, obj interface{}) (HTTPResponse, error)
+	Use(middlewares ...Middleware)
+	SetLogger(logger *slog.Logger)
+	SetDebug(debug bool)
+	SetTimeout(timeout time.Duration)
 }
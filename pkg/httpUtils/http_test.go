@@ -0,0 +1,52 @@
+package httpUtils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDebugLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"status":"success","data":{"user_id":"AB1234","access_token":"accesstoken456"}}`+strings.Repeat(" ", 2*debugBodyLimit))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := GenerateHttpClient(server.Client(), true)
+	client.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	client.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "token apikey123:accesstoken456")
+			return next(req)
+		}
+	})
+
+	_, err := client.Do(context.Background(), http.MethodPost, server.URL+"/session/token", url.Values{
+		"user_id":  {"AB1234"},
+		"password": {"hunter2"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"uri=/session/token", "status=200", "Authorization", "user_id=AB1234", `\"user_id\":\"AB1234\"`, "(8", "bytes)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %s:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"hunter2", "apikey123", "accesstoken456"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output contains %s:\n%s", secret, out)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package httpUtils
+
+import "net/http"
+
+// RoundTripFunc sends a prepared request and returns its response, like an
+// http.RoundTripper.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a RoundTripFunc with additional behaviour. A middleware
+// that changes the request should do so on a clone of it.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Chain composes middlewares around next so that the first middleware is the
+// outermost one.
+func Chain(next RoundTripFunc, middlewares ...Middleware) RoundTripFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next
+}
@@ -0,0 +1,100 @@
+package httpUtils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// tagging returns a middleware recording when it runs in calls and adding its
+// name to the X-Chain header of the requests it passes on.
+func tagging(name string, calls *[]string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name+" before")
+			req = req.Clone(req.Context())
+			req.Header.Add("X-Chain", name)
+			req.Header.Set("X-"+name, "set")
+			resp, err := next(req)
+			*calls = append(*calls, name+" after")
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	var (
+		requests atomic.Int64
+		received http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		received = r.Header.Clone()
+		io.WriteString(w, `{"status":"success","data":{}}`)
+	}))
+	defer server.Close()
+
+	t.Run("order and headers", func(t *testing.T) {
+		var calls []string
+		client := GenerateHttpClient(server.Client(), false)
+		client.Use(tagging("First", &calls), tagging("Second", &calls))
+
+		headers := http.Header{"X-Caller": {"set"}}
+		if _, err := client.Do(context.Background(), http.MethodGet, server.URL+"/user/profile", nil, headers); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "First before, Second before, Second after, First after"
+		if got := strings.Join(calls, ", "); got != want {
+			t.Errorf("middlewares ran %s, want %s", got, want)
+		}
+		if got := received.Values("X-Chain"); strings.Join(got, ", ") != "First, Second" {
+			t.Errorf("server got X-Chain %q, want First then Second", got)
+		}
+		for _, header := range []string{"X-First", "X-Second", "X-Caller"} {
+			if received.Get(header) != "set" {
+				t.Errorf("server didn't get %s in %v", header, received)
+			}
+		}
+		// The middlewares changed clones, the caller's headers are untouched.
+		if len(headers) != 1 {
+			t.Errorf("caller's headers changed to %v", headers)
+		}
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		var calls []string
+		cached := func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, "cached")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(`{"status":"success","data":"cached"}`)),
+					Request:    req,
+				}, nil
+			}
+		}
+		client := GenerateHttpClient(server.Client(), false)
+		client.Use(tagging("First", &calls), cached, tagging("Second", &calls))
+
+		before := requests.Load()
+		resp, err := client.Do(context.Background(), http.MethodGet, server.URL+"/user/profile", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(resp.Body), "cached") {
+			t.Errorf("response body = %s, want the cached one", resp.Body)
+		}
+		if got := strings.Join(calls, ", "); got != "First before, cached, First after" {
+			t.Errorf("middlewares ran %s, want the chain to stop at cached", got)
+		}
+		if requests.Load() != before {
+			t.Error("short-circuited request reached the server")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	httpUtils2 "github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// endpointWildcard replaces path parameters in endpoint names so order ids,
+// symbols and the like don't end up in metric labels.
+const endpointWildcard = "*"
+
+var endpointTemplates = compileEndpointTemplates(
+	constants.URIUserSession, constants.URIUserSessionRenew, constants.URIUserProfile,
+	constants.URIUserMargins, constants.URIUserMarginsSegment,
+	constants.URIGetOrders, constants.URIGetTrades, constants.URIGetOrderHistory,
+	constants.URIGetOrderTrades, constants.URIModifyOrder,
+	constants.URIGetPositions, constants.URIGetHoldings, constants.URIInitHoldingsAuth,
+	constants.URIAuctionInstruments,
+	constants.URIOrderMargins, constants.URIBasketMargins,
+	constants.URIGetMFOrders, constants.URIGetMFOrderInfo, constants.URIGetMFSIPs,
+	constants.URIGetMFSIPInfo, constants.URIGetMFHoldings, constants.URIGetMFHoldingInfo,
+	constants.URIGetAllotedISINs,
+	constants.URIGetGTTs, constants.URIGetGTT,
+	constants.URIGetInstruments, constants.URIGetMFInstruments, constants.URIGetInstrumentsExchange,
+	constants.URIGetHistorical, constants.URIGetTriggerRange,
+	constants.URIGetQuote, constants.URIGetLTP, constants.URIGetOHLC,
+)
+
+func compileEndpointTemplates(uris ...string) [][]string {
+	templates := make([][]string, 0, len(uris))
+	for _, uri := range uris {
+		segments := strings.Split(uri, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "%") {
+				segments[i] = endpointWildcard
+			}
+		}
+		templates = append(templates, segments)
+	}
+	return templates
+}
+
+// endpointName returns the URI template a request URI was built from, e.g.
+// `/orders/*/trades`, preferring the template with the fewest wildcards.
+// URIs matching no template are named `other`.
+func endpointName(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	segments := strings.Split(uri, "/")
+
+	var (
+		best          []string
+		bestWildcards int
+	)
+	for _, template := range endpointTemplates {
+		if len(template) != len(segments) {
+			continue
+		}
+		wildcards := 0
+		for i, segment := range template {
+			if segment == endpointWildcard {
+				wildcards++
+			} else if segment != segments[i] {
+				wildcards = -1
+				break
+			}
+		}
+		if wildcards >= 0 && (best == nil || wildcards < bestWildcards) {
+			best, bestWildcards = template, wildcards
+		}
+	}
+	if best == nil {
+		return "other"
+	}
+	return strings.Join(best, "/")
+}
+
+type endpointKey struct{}
+
+// SetRecorder sets the recorder every request attempt is measured with, by
+// endpoint, method and status.
+func (kiteHttpClient *KiteHttpClient) SetRecorder(recorder instrumentation.Recorder) {
+	kiteHttpClient.recorder = recorder
+}
+
+// SetTracer sets the tracer a span is started with for every call, covering
+// its rate limiting, retries and token refreshes.
+func (kiteHttpClient *KiteHttpClient) SetTracer(tracer instrumentation.Tracer) {
+	kiteHttpClient.tracer = tracer
+}
+
+func (kiteHttpClient *KiteHttpClient) recording() instrumentation.Recorder {
+	if kiteHttpClient.recorder == nil {
+		return instrumentation.Nop{}
+	}
+	return kiteHttpClient.recorder
+}
+
+func (kiteHttpClient *KiteHttpClient) tracing() instrumentation.Tracer {
+	if kiteHttpClient.tracer == nil {
+		return instrumentation.Nop{}
+	}
+	return kiteHttpClient.tracer
+}
+
+// startSpan starts the span of a call and tags ctx with its endpoint for the
+// request metrics.
+func (kiteHttpClient *KiteHttpClient) startSpan(ctx context.Context, method, uri string) (context.Context, instrumentation.Span) {
+	endpoint := endpointName(uri)
+	ctx, span := kiteHttpClient.tracing().Start(context.WithValue(ctx, endpointKey{}, endpoint), "kite "+method+" "+endpoint)
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("url.template", endpoint)
+	span.SetAttribute("kite.endpoint_class", string(endpointClass(method, uri)))
+	return ctx, span
+}
+
+func endSpan(span instrumentation.Span, attempts int, err error) {
+	span.SetAttribute("kite.attempts", attempts)
+	if err != nil {
+		var kiteErr httpUtils2.Error
+		if errors.As(err, &kiteErr) && kiteErr.Code != 0 {
+			span.SetAttribute("http.response.status_code", kiteErr.Code)
+		}
+		span.RecordError(err)
+		span.SetStatus(instrumentation.StatusError, err.Error())
+	} else {
+		span.SetStatus(instrumentation.StatusOK, "")
+	}
+	span.End()
+}
+
+// recordRequests is the default middleware measuring every request attempt.
+func (kiteHttpClient *KiteHttpClient) recordRequests(next httpUtils2.RoundTripFunc) httpUtils2.RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+
+		endpoint, ok := req.Context().Value(endpointKey{}).(string)
+		if !ok {
+			endpoint = "other"
+		}
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		kiteHttpClient.recording().ObserveRequest(endpoint, req.Method, status, time.Since(start))
+		return resp, err
+	}
+}
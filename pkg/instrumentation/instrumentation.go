@@ -0,0 +1,68 @@
+package instrumentation
+
+import (
+	"context"
+	"time"
+)
+
+// Recorder receives measurements from KiteHttpClient and Ticker. It must be
+// safe for concurrent use.
+type Recorder interface {
+	// ObserveRequest records a REST request attempt. Endpoint is the URI
+	// template of the request and status 0 means no response was received.
+	ObserveRequest(endpoint, method string, status int, latency time.Duration)
+	// ObserveFrame records a binary ticker frame carrying the given number of ticks.
+	ObserveFrame(ticks int)
+	// ObserveParseError records a ticker frame or packet that failed to parse.
+	ObserveParseError()
+	// ObserveReconnect records a ticker reconnect attempt.
+	ObserveReconnect(attempt int)
+	// ObserveTickLag records the delay between a tick's exchange timestamp
+	// and its receipt.
+	ObserveTickLag(lag time.Duration)
+}
+
+// Tracer starts a span for every KiteHttpClient call. Its methods mirror the
+// OpenTelemetry trace API so that an otel trace.Tracer is bridged with a thin
+// adapter, and the returned context is used for the request so propagators
+// see the span.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is the subset of an OpenTelemetry span used by the SDK.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	SetStatus(code StatusCode, description string)
+	End()
+}
+
+// StatusCode mirrors the OpenTelemetry span status codes.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusError
+	StatusOK
+)
+
+// Nop discards all measurements and spans.
+type Nop struct{}
+
+func (Nop) ObserveRequest(string, string, int, time.Duration) {}
+func (Nop) ObserveFrame(int)                                  {}
+func (Nop) ObserveParseError()                                {}
+func (Nop) ObserveReconnect(int)                              {}
+func (Nop) ObserveTickLag(time.Duration)                      {}
+
+func (Nop) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, interface{}) {}
+func (nopSpan) RecordError(error)                {}
+func (nopSpan) SetStatus(StatusCode, string)     {}
+func (nopSpan) End()                             {}
@@ -0,0 +1,264 @@
+package instrumentation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	requestBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// Exchange timestamps have a resolution of a second.
+	tickLagBuckets = []float64{1, 2, 3, 5, 10, 30, 60}
+)
+
+// Prometheus is a Recorder keeping its measurements in memory and exposing
+// them in the Prometheus text exposition format. It is an http.Handler so it
+// can be mounted on a /metrics route as is.
+type Prometheus struct {
+	namespace string
+
+	mu sync.Mutex
+	metrics
+}
+
+// metrics are the measurements of a Prometheus recorder.
+type metrics struct {
+	requests    map[requestKey]uint64
+	latencies   map[latencyKey]*histogram
+	frames      uint64
+	ticks       uint64
+	parseErrors uint64
+	reconnects  uint64
+	tickLag     *histogram
+}
+
+type requestKey struct {
+	endpoint string
+	method   string
+	status   int
+}
+
+type latencyKey struct {
+	endpoint string
+	method   string
+}
+
+// NewPrometheus returns a recorder whose metric names are prefixed with
+// namespace, e.g. `kite`.
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		namespace: namespace,
+		metrics: metrics{
+			requests:  map[requestKey]uint64{},
+			latencies: map[latencyKey]*histogram{},
+			tickLag:   newHistogram(tickLagBuckets),
+		},
+	}
+}
+
+func (p *Prometheus) ObserveRequest(endpoint, method string, status int, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests[requestKey{endpoint: endpoint, method: method, status: status}]++
+
+	key := latencyKey{endpoint: endpoint, method: method}
+	h, ok := p.latencies[key]
+	if !ok {
+		h = newHistogram(requestBuckets)
+		p.latencies[key] = h
+	}
+	h.observe(latency.Seconds())
+}
+
+func (p *Prometheus) ObserveFrame(ticks int) {
+	p.mu.Lock()
+	p.frames++
+	p.ticks += uint64(ticks)
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) ObserveParseError() {
+	p.mu.Lock()
+	p.parseErrors++
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) ObserveReconnect(int) {
+	p.mu.Lock()
+	p.reconnects++
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) ObserveTickLag(lag time.Duration) {
+	p.mu.Lock()
+	p.tickLag.observe(lag.Seconds())
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes all the metrics in the text exposition format. It writes a
+// copy of the metrics so a slow writer doesn't hold up recording.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	m := p.snapshot()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	name := p.name("http_requests_total")
+	cw.printf("# HELP %s Kite REST request attempts by endpoint, method and status.\n# TYPE %s counter\n", name, name)
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.endpoint != b.endpoint {
+			return a.endpoint < b.endpoint
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, key := range requestKeys {
+		status := "error"
+		if key.status != 0 {
+			status = strconv.Itoa(key.status)
+		}
+		cw.printf("%s{endpoint=%s,method=%s,status=%s} %d\n", name, quote(key.endpoint), quote(key.method), quote(status), m.requests[key])
+	}
+
+	name = p.name("http_request_duration_seconds")
+	cw.printf("# HELP %s Kite REST request attempt latency.\n# TYPE %s histogram\n", name, name)
+	latencyKeys := make([]latencyKey, 0, len(m.latencies))
+	for key := range m.latencies {
+		latencyKeys = append(latencyKeys, key)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool {
+		a, b := latencyKeys[i], latencyKeys[j]
+		if a.endpoint != b.endpoint {
+			return a.endpoint < b.endpoint
+		}
+		return a.method < b.method
+	})
+	for _, key := range latencyKeys {
+		m.latencies[key].write(cw, name, fmt.Sprintf("endpoint=%s,method=%s", quote(key.endpoint), quote(key.method)))
+	}
+
+	p.writeCounter(cw, "ticker_frames_total", "Binary frames received by the ticker.", m.frames)
+	p.writeCounter(cw, "ticker_ticks_total", "Ticks parsed by the ticker.", m.ticks)
+	p.writeCounter(cw, "ticker_parse_errors_total", "Ticker frames or packets that failed to parse.", m.parseErrors)
+	p.writeCounter(cw, "ticker_reconnect_attempts_total", "Ticker reconnect attempts.", m.reconnects)
+
+	name = p.name("ticker_tick_lag_seconds")
+	cw.printf("# HELP %s Delay between a tick's exchange timestamp and its receipt.\n# TYPE %s histogram\n", name, name)
+	m.tickLag.write(cw, name, "")
+
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// snapshot returns a copy of the metrics.
+func (p *Prometheus) snapshot() metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := p.metrics
+	m.requests = make(map[requestKey]uint64, len(p.requests))
+	for key, count := range p.requests {
+		m.requests[key] = count
+	}
+	m.latencies = make(map[latencyKey]*histogram, len(p.latencies))
+	for key, h := range p.latencies {
+		m.latencies[key] = h.clone()
+	}
+	m.tickLag = p.tickLag.clone()
+	return m
+}
+
+func (p *Prometheus) writeCounter(cw *countingWriter, metric, help string, value uint64) {
+	name := p.name(metric)
+	cw.printf("# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
+
+func (p *Prometheus) name(metric string) string {
+	if p.namespace == "" {
+		return metric
+	}
+	return p.namespace + "_" + metric
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) clone() *histogram {
+	c := *h
+	c.counts = append([]uint64(nil), h.counts...)
+	return &c
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(cw *countingWriter, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, bound := range h.buckets {
+		cw.printf("%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	cw.printf("%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	cw.printf("%s_sum%s %s\n%s_count%s %d\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64), name, labels, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
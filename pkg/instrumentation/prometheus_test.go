@@ -0,0 +1,132 @@
+package instrumentation
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	p := NewPrometheus("kite")
+	p.ObserveRequest("orders", "GET", 200, 20*time.Millisecond)
+	p.ObserveRequest("orders", "GET", 0, 3*time.Second)
+	p.ObserveRequest("quote", "GET", 429, time.Millisecond)
+	p.ObserveFrame(3)
+	p.ObserveFrame(0)
+	p.ObserveParseError()
+	p.ObserveReconnect(1)
+	p.ObserveTickLag(500 * time.Millisecond)
+	p.ObserveTickLag(4 * time.Second)
+
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo() = %d, %v, wrote %d bytes", n, err, buf.Len())
+	}
+
+	want := `# HELP kite_http_requests_total Kite REST request attempts by endpoint, method and status.
+# TYPE kite_http_requests_total counter
+kite_http_requests_total{endpoint="orders",method="GET",status="error"} 1
+kite_http_requests_total{endpoint="orders",method="GET",status="200"} 1
+kite_http_requests_total{endpoint="quote",method="GET",status="429"} 1
+# HELP kite_http_request_duration_seconds Kite REST request attempt latency.
+# TYPE kite_http_request_duration_seconds histogram
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.005"} 0
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.01"} 0
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.025"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.05"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.1"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.25"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="0.5"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="1"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="2.5"} 1
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="5"} 2
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="10"} 2
+kite_http_request_duration_seconds_bucket{endpoint="orders",method="GET",le="+Inf"} 2
+kite_http_request_duration_seconds_sum{endpoint="orders",method="GET"} 3.02
+kite_http_request_duration_seconds_count{endpoint="orders",method="GET"} 2
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.005"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.01"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.025"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.05"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.1"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.25"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="0.5"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="1"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="2.5"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="5"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="10"} 1
+kite_http_request_duration_seconds_bucket{endpoint="quote",method="GET",le="+Inf"} 1
+kite_http_request_duration_seconds_sum{endpoint="quote",method="GET"} 0.001
+kite_http_request_duration_seconds_count{endpoint="quote",method="GET"} 1
+# HELP kite_ticker_frames_total Binary frames received by the ticker.
+# TYPE kite_ticker_frames_total counter
+kite_ticker_frames_total 2
+# HELP kite_ticker_ticks_total Ticks parsed by the ticker.
+# TYPE kite_ticker_ticks_total counter
+kite_ticker_ticks_total 3
+# HELP kite_ticker_parse_errors_total Ticker frames or packets that failed to parse.
+# TYPE kite_ticker_parse_errors_total counter
+kite_ticker_parse_errors_total 1
+# HELP kite_ticker_reconnect_attempts_total Ticker reconnect attempts.
+# TYPE kite_ticker_reconnect_attempts_total counter
+kite_ticker_reconnect_attempts_total 1
+# HELP kite_ticker_tick_lag_seconds Delay between a tick's exchange timestamp and its receipt.
+# TYPE kite_ticker_tick_lag_seconds histogram
+kite_ticker_tick_lag_seconds_bucket{le="1"} 1
+kite_ticker_tick_lag_seconds_bucket{le="2"} 1
+kite_ticker_tick_lag_seconds_bucket{le="3"} 1
+kite_ticker_tick_lag_seconds_bucket{le="5"} 2
+kite_ticker_tick_lag_seconds_bucket{le="10"} 2
+kite_ticker_tick_lag_seconds_bucket{le="30"} 2
+kite_ticker_tick_lag_seconds_bucket{le="60"} 2
+kite_ticker_tick_lag_seconds_bucket{le="+Inf"} 2
+kite_ticker_tick_lag_seconds_sum 4.5
+kite_ticker_tick_lag_seconds_count 2
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo() wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+// blockingWriter blocks every write until released.
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return len(p), nil
+}
+
+// TestPrometheusSlowScrape checks a slow scraper doesn't hold up recording.
+func TestPrometheusSlowScrape(t *testing.T) {
+	p := NewPrometheus("kite")
+	w := blockingWriter{writing: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(w.release)
+
+	// Enough series to overflow the write buffer.
+	for i := 0; i < 200; i++ {
+		p.ObserveRequest(string(rune('a'+i%26))+string(rune('a'+i/26)), "GET", 200, time.Millisecond)
+	}
+	go p.WriteTo(io.Writer(w))
+	<-w.writing
+
+	observed := make(chan struct{})
+	go func() {
+		p.ObserveFrame(1)
+		p.ObserveTickLag(time.Second)
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Fatal("recording blocked behind a slow WriteTo")
+	}
+}
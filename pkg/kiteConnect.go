@@ -1,17 +1,126 @@
 package pkg
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	httpUtils2 "github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"time"
 )
 
-func KiteConnect(encToken string, apiKey string) *KiteHttpClient {
-	client := &KiteHttpClient{}
-	client.SetHTTPClient(&http.Client{
-		Timeout: constants.RequestTimeout,
-	})
-	client.SetBaseURI(constants.BaseURI)
-	client.SetEncToken(encToken)
-	client.SetApiKey(apiKey)
-	return client
+// Option configures the client built by KiteConnect.
+type Option func(*clientOptions) error
+
+type clientOptions struct {
+	backend     Backend
+	baseURI     string
+	apiKey      string
+	encToken    string
+	accessToken string
+	authMode    AuthMode
+	debug       bool
+
+	transport http.RoundTripper
+	proxy     *url.URL
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	userAgent string
+
+	logger      *slog.Logger
+	rateLimiter *RateLimiter
+	retryPolicy RetryPolicy
+	middlewares []httpUtils2.Middleware
+	recorder    instrumentation.Recorder
+	tracer      instrumentation.Tracer
+}
+
+// KiteConnect returns a client for the Kite REST API. Without options it
+// talks to the Kite Connect backend with the default timeout, retry policy
+// and rate limits. Invalid options fail the construction with a descriptive error.
+func KiteConnect(opts ...Option) (*KiteHttpClient, error) {
+	options := clientOptions{
+		timeout:     constants.RequestTimeout,
+		userAgent:   constants.Name + "/" + constants.Version,
+		rateLimiter: NewDefaultRateLimiter(),
+		retryPolicy: DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, err
+		}
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := options.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &KiteHttpClient{debug: options.debug}
+	if options.logger != nil {
+		client.SetLogger(options.logger)
+	}
+	client.Use(options.middlewares...)
+	client.SetHTTPClient(httpClient)
+	client.SetBackend(options.backend)
+	if options.baseURI != "" {
+		client.SetBaseURI(options.baseURI)
+	}
+	client.SetUserAgent(options.userAgent)
+	client.SetApiKey(options.apiKey)
+	client.SetEncToken(options.encToken)
+	client.SetAccessToken(options.accessToken)
+	client.SetAuthMode(options.authMode)
+	client.SetRetryPolicy(options.retryPolicy)
+	client.SetRateLimiter(options.rateLimiter)
+	client.SetRecorder(options.recorder)
+	client.SetTracer(options.tracer)
+	return client, nil
+}
+
+func (options *clientOptions) validate() error {
+	// AuthModeAuto falls back to the access token without an enctoken.
+	usesAccessToken := options.authMode == AuthModeAccessToken ||
+		options.authMode == AuthModeAuto && (options.backend.authMode() == AuthModeAccessToken || options.encToken == "")
+	if usesAccessToken && options.accessToken != "" && options.apiKey == "" {
+		return errors.New("the access token auth mode requires an API key")
+	}
+	if (options.proxy != nil || options.tlsConfig != nil) && options.transport != nil {
+		if _, ok := options.transport.(*http.Transport); !ok {
+			return fmt.Errorf("proxy and TLS options require an *http.Transport, got %T", options.transport)
+		}
+	}
+	return nil
+}
+
+// httpClient builds the http.Client from the transport options. The proxy
+// and TLS config are applied to a clone of the transport, leaving the one
+// passed to WithTransport untouched.
+func (options *clientOptions) httpClient() (*http.Client, error) {
+	transport := options.transport
+	if options.proxy != nil || options.tlsConfig != nil {
+		base, ok := transport.(*http.Transport)
+		if transport == nil {
+			base, ok = http.DefaultTransport.(*http.Transport)
+		}
+		if !ok {
+			return nil, fmt.Errorf("proxy and TLS options require an *http.Transport, got %T", transport)
+		}
+		base = base.Clone()
+		if options.proxy != nil {
+			base.Proxy = http.ProxyURL(options.proxy)
+		}
+		if options.tlsConfig != nil {
+			base.TLSClientConfig = options.tlsConfig
+		}
+		transport = base
+	}
+	return &http.Client{Transport: transport, Timeout: options.timeout}, nil
 }
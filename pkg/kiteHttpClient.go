@@ -1,30 +1,105 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
 	httpUtils2 "github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"github.com/algotuners/zerodha-sdk-go/pkg/logging"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
+// AuthMode selects the Authorization scheme sent with every request.
+type AuthMode int
+
+const (
+	// AuthModeAuto authenticates as the backend expects, with the access
+	// token on BackendConnect and the enctoken on BackendWeb, falling back to
+	// the other credential when only that one is configured.
+	AuthModeAuto AuthMode = iota
+	// AuthModeEncToken authenticates as a Kite web session: `enctoken <enctoken>`.
+	AuthModeEncToken
+	// AuthModeAccessToken authenticates as a Kite Connect app: `token <api_key>:<access_token>`.
+	AuthModeAccessToken
+)
+
 type KiteHttpClient struct {
 	encToken    string
 	apiKey      string
 	accessToken string
+	authMode    AuthMode
+	backend     Backend
 	debug       bool
 	baseURI     string
+	userAgent   string
 	httpClient  httpUtils2.HTTPClient
+
+	// credentialsMu guards encToken and accessToken which may be swapped by a
+	// token refresh while other requests are in flight.
+	credentialsMu  sync.RWMutex
+	refreshMu      sync.Mutex
+	tokenProvider  TokenProvider
+	onTokenRefresh []func(Credentials)
+
+	retryPolicy RetryPolicy
+	rateLimiter *RateLimiter
+	middlewares []httpUtils2.Middleware
+	logger      *slog.Logger
+	recorder    instrumentation.Recorder
+	tracer      instrumentation.Tracer
 }
 
+// SetHTTPClient sets the http.Client requests are sent with. Requests pass
+// through the Kite headers, request logging and metrics middlewares followed
+// by the middlewares added with Use.
 func (kiteHttpClient *KiteHttpClient) SetHTTPClient(h *http.Client) {
 	kiteHttpClient.httpClient = httpUtils2.GenerateHttpClient(h, kiteHttpClient.debug)
+	kiteHttpClient.httpClient.SetLogger(kiteHttpClient.log())
+	kiteHttpClient.httpClient.Use(kiteHttpClient.kiteHeaders, kiteHttpClient.logRequests, kiteHttpClient.recordRequests)
+	kiteHttpClient.httpClient.Use(kiteHttpClient.middlewares...)
 }
 
+// Use appends middlewares to the chain every request is sent through, e.g.
+// for logging, metrics, tracing or request signing.
+func (kiteHttpClient *KiteHttpClient) Use(middlewares ...httpUtils2.Middleware) {
+	kiteHttpClient.middlewares = append(kiteHttpClient.middlewares, middlewares...)
+	if kiteHttpClient.httpClient != nil {
+		kiteHttpClient.httpClient.Use(middlewares...)
+	}
+}
+
+// SetLogger sets the logger for the client. Tokens, secrets and checksums are
+// redacted from every record regardless of the handler.
+func (kiteHttpClient *KiteHttpClient) SetLogger(logger *slog.Logger) {
+	kiteHttpClient.logger = logging.New(logger)
+	if kiteHttpClient.httpClient != nil {
+		kiteHttpClient.httpClient.SetLogger(kiteHttpClient.logger)
+	}
+}
+
+func (kiteHttpClient *KiteHttpClient) log() *slog.Logger {
+	if kiteHttpClient.logger == nil {
+		return logging.New(nil)
+	}
+	return kiteHttpClient.logger
+}
+
+// SetDebug toggles debug logging of every completed request: its method, URI,
+// status, the headers sent and the request and response bodies, truncated to
+// 4KB. Credentials are redacted. It may be called before SetHTTPClient, which
+// picks the setting up.
 func (kiteHttpClient *KiteHttpClient) SetDebug(debug bool) {
 	kiteHttpClient.debug = debug
-	kiteHttpClient.httpClient.GetClient().Debug = debug
+	if kiteHttpClient.httpClient != nil {
+		kiteHttpClient.httpClient.SetDebug(debug)
+	}
 }
 
 func (kiteHttpClient *KiteHttpClient) SetBaseURI(baseURI string) {
@@ -32,71 +107,290 @@ func (kiteHttpClient *KiteHttpClient) SetBaseURI(baseURI string) {
 }
 
 func (kiteHttpClient *KiteHttpClient) SetTimeout(timeout time.Duration) {
-	hClient := kiteHttpClient.httpClient.GetClient().Client
-	hClient.Timeout = timeout
+	if kiteHttpClient.httpClient != nil {
+		kiteHttpClient.httpClient.SetTimeout(timeout)
+	}
+}
+
+// SetUserAgent sets the User-Agent sent with every request. An empty user
+// agent restores the default.
+func (kiteHttpClient *KiteHttpClient) SetUserAgent(userAgent string) {
+	kiteHttpClient.userAgent = userAgent
 }
 
 func (kiteHttpClient *KiteHttpClient) SetEncToken(encToken string) {
+	kiteHttpClient.credentialsMu.Lock()
 	kiteHttpClient.encToken = encToken
+	kiteHttpClient.credentialsMu.Unlock()
 }
 
 func (kiteHttpClient *KiteHttpClient) SetAccessToken(accessToken string) {
+	kiteHttpClient.credentialsMu.Lock()
 	kiteHttpClient.accessToken = accessToken
+	kiteHttpClient.credentialsMu.Unlock()
 }
 
 func (kiteHttpClient *KiteHttpClient) SetApiKey(apiKey string) {
 	kiteHttpClient.apiKey = apiKey
 }
 
+func (kiteHttpClient *KiteHttpClient) SetAuthMode(authMode AuthMode) {
+	kiteHttpClient.authMode = authMode
+}
+
 func (kiteHttpClient *KiteHttpClient) GetEncToken() string {
+	kiteHttpClient.credentialsMu.RLock()
+	defer kiteHttpClient.credentialsMu.RUnlock()
 	return kiteHttpClient.encToken
 }
 
+func (kiteHttpClient *KiteHttpClient) GetAccessToken() string {
+	kiteHttpClient.credentialsMu.RLock()
+	defer kiteHttpClient.credentialsMu.RUnlock()
+	return kiteHttpClient.accessToken
+}
+
+// authorization returns the Authorization header value for the configured
+// auth mode, or an empty string when the required credential isn't set.
+// AuthModeAuto prefers the backend's scheme and falls back to the other one
+// when only its credential is set.
+func (kiteHttpClient *KiteHttpClient) authorization() string {
+	kiteHttpClient.credentialsMu.RLock()
+	defer kiteHttpClient.credentialsMu.RUnlock()
+
+	if kiteHttpClient.authMode != AuthModeAuto {
+		return kiteHttpClient.authorizationFor(kiteHttpClient.authMode)
+	}
+
+	preferred, fallback := AuthModeAccessToken, AuthModeEncToken
+	if kiteHttpClient.backend.authMode() == AuthModeEncToken {
+		preferred, fallback = fallback, preferred
+	}
+	if authHeader := kiteHttpClient.authorizationFor(preferred); authHeader != "" {
+		return authHeader
+	}
+	return kiteHttpClient.authorizationFor(fallback)
+}
+
+// authorizationFor returns the Authorization header value of authMode, or an
+// empty string when its credential isn't set. credentialsMu must be held.
+func (kiteHttpClient *KiteHttpClient) authorizationFor(authMode AuthMode) string {
+	switch authMode {
+	case AuthModeAccessToken:
+		if kiteHttpClient.accessToken != "" {
+			return fmt.Sprintf("token %s:%s", kiteHttpClient.apiKey, kiteHttpClient.accessToken)
+		}
+	case AuthModeEncToken:
+		if kiteHttpClient.encToken != "" {
+			return fmt.Sprintf("enctoken %s", kiteHttpClient.encToken)
+		}
+	}
+	return ""
+}
+
 func (kiteHttpClient *KiteHttpClient) GetLoginURL() string {
 	return fmt.Sprintf("%s/connect/login?api_key=%s&v=%s", constants.KiteBaseURI, kiteHttpClient.apiKey, constants.KiteHeaderVersion)
 }
 
-func (kiteHttpClient *KiteHttpClient) doEnvelope(method, uri string, params url.Values, headers http.Header, v interface{}) error {
-	if params == nil {
-		params = url.Values{}
+// kiteHeaders is the default middleware adding the Kite version, user agent
+// and current Authorization to every request.
+func (kiteHttpClient *KiteHttpClient) kiteHeaders(next httpUtils2.RoundTripFunc) httpUtils2.RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Kite-Version", constants.KiteHeaderVersion)
+		userAgent := kiteHttpClient.userAgent
+		if userAgent == "" {
+			userAgent = constants.Name + "/" + constants.Version
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if authHeader := kiteHttpClient.authorization(); authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		return next(req)
 	}
-	if headers == nil {
-		headers = map[string][]string{}
+}
+
+// logRequests is the default middleware logging every request with an id,
+// its latency and status. Failures are logged as warnings, the rest at debug.
+func (kiteHttpClient *KiteHttpClient) logRequests(next httpUtils2.RoundTripFunc) httpUtils2.RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		var (
+			logger    = kiteHttpClient.log()
+			requestID = fmt.Sprintf("%016x", rand.Uint64())
+			start     = time.Now()
+		)
+
+		resp, err := next(req)
+
+		attrs := []any{"request_id", requestID, "method", req.Method, "path", req.URL.Path, "latency", time.Since(start)}
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			logger.Debug("Kite request cancelled", append(attrs, "error", err)...)
+		case err != nil:
+			logger.Warn("Kite request failed", append(attrs, "error", err)...)
+		case resp.StatusCode >= http.StatusBadRequest:
+			logger.Warn("Kite request failed", append(attrs, "status", resp.StatusCode)...)
+		default:
+			logger.Debug("Kite request", append(attrs, "status", resp.StatusCode)...)
+		}
+		return resp, err
+	}
+}
+
+// execute runs send once the rate limiter allows it, retrying it as allowed
+// by the retry policy. The whole call is traced in a single span.
+func (kiteHttpClient *KiteHttpClient) execute(ctx context.Context, method, uri string, send func(ctx context.Context) error) (err error) {
+	ctx, span := kiteHttpClient.startSpan(ctx, method, uri)
+	attempts := 0
+	defer func() { endSpan(span, attempts, err) }()
+
+	var (
+		retryPolicy = kiteHttpClient.retryPolicy
+		retryable   = retryPolicy.allows(method, uri)
+		rateLimiter = kiteHttpClient.rateLimiter
+		class       = endpointClass(method, uri)
+	)
+
+	// sent records whether the request may have reached Kite.
+	var sent bool
+	if rateLimiter != nil && isOrderPlacement(method, uri) {
+		release, reserveErr := rateLimiter.ReserveOrder()
+		if reserveErr != nil {
+			return reserveErr
+		}
+		// Orders that were never sent or were rejected aren't placed and
+		// don't count toward the caps. Those failing in flight may have been.
+		defer func() {
+			if err != nil && (!sent || isRejection(err)) {
+				release()
+			}
+		}()
+	}
+
+	// Every send, including the replay after a token refresh, waits for the
+	// rate limiter.
+	throttled := func(ctx context.Context) error {
+		if rateLimiter != nil {
+			if err := rateLimiter.Wait(ctx, class); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sent = true
+		return send(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		attempts = attempt
+		err = kiteHttpClient.attempt(ctx, method, uri, throttled)
+		if err == nil || !retryable || attempt >= retryPolicy.MaxAttempts || !httpUtils2.IsRetryable(err) {
+			return err
+		}
+
+		delay := retryPolicy.backoff(attempt, err)
+		kiteHttpClient.log().Warn("Retrying Kite request", "method", method, "uri", uri, "attempt", attempt+1, "delay", delay, "error", err)
+		if retryPolicy.OnRetry != nil {
+			retryPolicy.OnRetry(RetryEvent{Method: method, URI: uri, Attempt: attempt + 1, Delay: delay, Err: err})
+		}
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+}
+
+// attempt runs send and, when it fails with a TokenException, refreshes the
+// credentials through the TokenProvider and replays idempotent requests once.
+func (kiteHttpClient *KiteHttpClient) attempt(ctx context.Context, method, uri string, send func(ctx context.Context) error) error {
+	staleAuth := kiteHttpClient.authorization()
+	err := send(ctx)
+	if !kiteHttpClient.shouldRefresh(uri, err) {
+		return err
 	}
-	headers.Add("X-Kite-Version", constants.KiteHeaderVersion)
-	headers.Add("User-Agent", constants.Name+"/"+constants.Version)
-	if kiteHttpClient.encToken != "" {
-		authHeader := fmt.Sprintf("enctoken %s", kiteHttpClient.encToken)
-		headers.Add("Authorization", authHeader)
+
+	if refreshErr := kiteHttpClient.refreshCredentials(ctx, staleAuth); refreshErr != nil {
+		return err
+	}
+
+	if !isIdempotent(method) {
+		return err
 	}
-	return kiteHttpClient.httpClient.DoEnvelope(method, kiteHttpClient.baseURI+uri, params, headers, v)
+	return send(ctx)
 }
 
-func (kiteHttpClient *KiteHttpClient) do(method, uri string, params url.Values, headers http.Header) (httpUtils2.HTTPResponse, error) {
+func (kiteHttpClient *KiteHttpClient) doEnvelope(ctx context.Context, method, uri string, params url.Values, headers http.Header, v interface{}) error {
 	if params == nil {
 		params = url.Values{}
 	}
-	if headers == nil {
-		headers = map[string][]string{}
+	return kiteHttpClient.execute(ctx, method, uri, func(ctx context.Context) error {
+		return kiteHttpClient.httpClient.DoEnvelope(ctx, method, kiteHttpClient.baseURI+uri, params, headers, v)
+	})
+}
+
+// do returns the raw response of a request. Error responses are decoded from
+// their envelope and returned as the error.
+func (kiteHttpClient *KiteHttpClient) do(ctx context.Context, method, uri string, params url.Values, headers http.Header) (httpUtils2.HTTPResponse, error) {
+	var resp httpUtils2.HTTPResponse
+	if params == nil {
+		params = url.Values{}
 	}
-	headers.Add("X-Kite-Version", constants.KiteHeaderVersion)
-	headers.Add("User-Agent", constants.Name+"/"+constants.Version)
-	if kiteHttpClient.encToken != "" {
-		authHeader := fmt.Sprintf("enctoken %s", kiteHttpClient.encToken)
-		headers.Add("Authorization", authHeader)
+	err := kiteHttpClient.execute(ctx, method, uri, func(ctx context.Context) error {
+		var err error
+		if resp, err = kiteHttpClient.httpClient.Do(ctx, method, kiteHttpClient.baseURI+uri, params, headers); err != nil {
+			return err
+		}
+		return readErrorEnvelope(resp)
+	})
+	return resp, err
+}
+
+func (kiteHttpClient *KiteHttpClient) doRaw(ctx context.Context, method, uri string, reqBody []byte, headers http.Header) (httpUtils2.HTTPResponse, error) {
+	var resp httpUtils2.HTTPResponse
+	err := kiteHttpClient.execute(ctx, method, uri, func(ctx context.Context) error {
+		var err error
+		if resp, err = kiteHttpClient.httpClient.DoRaw(ctx, method, kiteHttpClient.baseURI+uri, reqBody, headers); err != nil {
+			return err
+		}
+		return readErrorEnvelope(resp)
+	})
+	return resp, err
+}
+
+func (kiteHttpClient *KiteHttpClient) doRawEnvelope(ctx context.Context, method, uri string, reqBody []byte, headers http.Header, v interface{}) error {
+	resp, err := kiteHttpClient.doRaw(ctx, method, uri, reqBody, headers)
+	if err != nil {
+		return err
+	}
+	return httpUtils2.ReadEnvelope(resp, v)
+}
+
+func readErrorEnvelope(resp httpUtils2.HTTPResponse) error {
+	if resp.Response.StatusCode >= http.StatusBadRequest {
+		return httpUtils2.ReadEnvelope(resp, nil)
 	}
-	return kiteHttpClient.httpClient.Do(method, kiteHttpClient.baseURI+uri, params, headers)
+	return nil
 }
 
-func (kiteHttpClient *KiteHttpClient) doRaw(method, uri string, reqBody []byte, headers http.Header) (httpUtils2.HTTPResponse, error) {
-	if headers == nil {
-		headers = map[string][]string{}
+// isRejection reports whether err is an error response refusing the request.
+// Gateway errors are answered by proxies whatever became of the request
+// upstream, so they aren't.
+func isRejection(err error) bool {
+	var kiteErr httpUtils2.Error
+	if !errors.As(err, &kiteErr) || kiteErr.Code < http.StatusBadRequest {
+		return false
+	}
+	switch kiteErr.Code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return false
 	}
-	headers.Add("X-Kite-Version", constants.KiteHeaderVersion)
-	headers.Add("User-Agent", constants.Name+"/"+constants.Version)
-	if kiteHttpClient.encToken != "" {
-		authHeader := fmt.Sprintf("enctoken %s", kiteHttpClient.encToken)
-		headers.Add("Authorization", authHeader)
+	return true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
 	}
-	return kiteHttpClient.httpClient.DoRaw(method, kiteHttpClient.baseURI+uri, reqBody, headers)
+	return false
 }
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"connect access token", []Option{WithAPIKey("key"), WithAccessToken("access")}, "token key:access"},
+		{"connect enctoken only", []Option{WithEncToken("enc")}, "enctoken enc"},
+		{"connect both", []Option{WithAPIKey("key"), WithAccessToken("access"), WithEncToken("enc")}, "token key:access"},
+		{"web enctoken", []Option{WithBackend(BackendWeb), WithEncToken("enc")}, "enctoken enc"},
+		{"web access token only", []Option{WithBackend(BackendWeb), WithAPIKey("key"), WithAccessToken("access")}, "token key:access"},
+		{"web both", []Option{WithBackend(BackendWeb), WithAPIKey("key"), WithAccessToken("access"), WithEncToken("enc")}, "enctoken enc"},
+		{"explicit mode without its credential", []Option{WithAuthMode(AuthModeAccessToken), WithEncToken("enc")}, ""},
+		{"none", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := KiteConnect(tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := client.authorization(); got != tt.want {
+				t.Errorf("authorization() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	// The server holds every request until the client gives up on it.
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := KiteConnect(WithAPIKey("key"), WithAccessToken("access"), WithBaseURL(server.URL), WithRateLimiter(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-received
+			cancel()
+		}()
+		if _, err := client.GetUserProfileWithContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetUserProfileWithContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := client.PlaceOrderWithContext(ctx, "regular", OrderParams{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("PlaceOrderWithContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		<-received
+	})
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces every secret in log output.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys and header names whose values are always
+// redacted, matched case insensitively.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"api_key":       true,
+	"enctoken":      true,
+	"access_token":  true,
+	"refresh_token": true,
+	"request_token": true,
+	"public_token":  true,
+	"api_secret":    true,
+	"checksum":      true,
+	"password":      true,
+	"twofa_value":   true,
+	"totp_secret":   true,
+}
+
+var secretPatterns = []*regexp.Regexp{
+	// key=value, key: value and "key":"value" pairs in queries, forms and JSON.
+	regexp.MustCompile(`(?i)("?\b(?:api_key|enctoken|access_token|refresh_token|request_token|public_token|api_secret|checksum|password|twofa_value|totp_secret)"?\s*[=:]\s*"?)[^\s&"',;\]\}]+`),
+	// Authorization header values.
+	regexp.MustCompile(`(?i)(\benctoken\s+)[^\s"',;\]\}]+`),
+	regexp.MustCompile(`(?i)(\btoken\s+)[^\s:"',;\]\}]+:[^\s"',;\]\}]+`),
+	regexp.MustCompile(`(?i)(\bbearer\s+)[^\s"',;\]\}]+`),
+}
+
+// Redact masks API keys, tokens, API secrets, checksums and passwords in s.
+func Redact(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// RedactHeader returns a copy of h with credentials masked.
+func RedactHeader(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for key, values := range h {
+		masked := make([]string, len(values))
+		for i, value := range values {
+			if sensitiveKeys[strings.ToLower(key)] {
+				masked[i] = Redacted
+			} else {
+				masked[i] = Redact(value)
+			}
+		}
+		redacted[key] = masked
+	}
+	return redacted
+}
+
+// New wraps logger so that every record it emits is redacted. A nil logger
+// wraps slog.Default().
+func New(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, ok := logger.Handler().(redactingHandler); ok {
+		return logger
+	}
+	return slog.New(NewHandler(logger.Handler()))
+}
+
+// NewHandler returns a handler redacting the message and every attribute
+// before passing records on to next.
+func NewHandler(next slog.Handler) slog.Handler {
+	return redactingHandler{next: next}
+}
+
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return redactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case http.Header:
+			return slog.Any(attr.Key, RedactHeader(v))
+		case error:
+			return slog.String(attr.Key, Redact(v.Error()))
+		case *url.URL:
+			return slog.String(attr.Key, Redact(v.String()))
+		default:
+			// Anything else could render a secret, so log its redacted text.
+			return slog.String(attr.Key, Redact(fmt.Sprint(v)))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
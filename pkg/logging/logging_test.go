@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Secrets the tests log, none of which may reach the output.
+var secrets = []string{"apikey123", "accesstoken456", "enc/token+789==", "hunter2", "reqtoken000"}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"wss://ws.kite.trade?api_key=apikey123&access_token=accesstoken456", "wss://ws.kite.trade?api_key=[REDACTED]&access_token=[REDACTED]"},
+		{"wss://ws.zerodha.com?api_key=kitefront&user_id=AB1234&enctoken=enc%2Ftoken%2B789%3D%3D", "wss://ws.zerodha.com?api_key=[REDACTED]&user_id=AB1234&enctoken=[REDACTED]"},
+		{"Authorization: token apikey123:accesstoken456", "Authorization: token [REDACTED]"},
+		{"enctoken enc/token+789==", "enctoken [REDACTED]"},
+		{`{"password":"hunter2","user_id":"AB1234"}`, `{"password":"[REDACTED]","user_id":"AB1234"}`},
+		{"request_token: reqtoken000", "request_token: [REDACTED]"},
+		{"Invalid token provided", "Invalid token provided"},
+	}
+
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{
+		"Authorization":  {"token apikey123:accesstoken456"},
+		"Cookie":         {"enctoken=enc/token+789=="},
+		"X-Kite-Version": {"3"},
+		"Referer":        {"https://kite.zerodha.com/?request_token=reqtoken000"},
+	}
+	redacted := RedactHeader(h)
+
+	if redacted.Get("Authorization") != Redacted || redacted.Get("Cookie") != Redacted {
+		t.Errorf("credential headers = %v, want them redacted", redacted)
+	}
+	if redacted.Get("X-Kite-Version") != "3" {
+		t.Errorf("X-Kite-Version = %q, want it kept", redacted.Get("X-Kite-Version"))
+	}
+	if strings.Contains(redacted.Get("Referer"), "reqtoken000") {
+		t.Errorf("Referer = %q, want the request token redacted", redacted.Get("Referer"))
+	}
+	if h.Get("Authorization") == Redacted {
+		t.Error("RedactHeader modified its argument")
+	}
+}
+
+type credentials struct {
+	APIKey      string
+	AccessToken string
+}
+
+func (c credentials) String() string {
+	return "api_key=" + c.APIKey + " access_token=" + c.AccessToken
+}
+
+func TestHandler(t *testing.T) {
+	for name, newHandler := range map[string]func(*bytes.Buffer) slog.Handler{
+		"text": func(buf *bytes.Buffer) slog.Handler { return slog.NewTextHandler(buf, nil) },
+		"json": func(buf *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(buf, nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(slog.New(newHandler(&buf)))
+
+			tickerURL, _ := url.Parse("wss://ws.kite.trade?api_key=apikey123&access_token=accesstoken456")
+			logger.Info("Connecting with access_token=accesstoken456",
+				"url", tickerURL,
+				"query", tickerURL.RawQuery,
+				"api_key", "apikey123",
+				"error", errors.New("request failed: Authorization: enctoken enc/token+789=="),
+				"header", http.Header{"Authorization": {"token apikey123:accesstoken456"}},
+				"credentials", credentials{APIKey: "apikey123", AccessToken: "accesstoken456"},
+				slog.Group("login", "password", "hunter2", slog.Group("session", "request_token", "reqtoken000", "form", "enctoken=enc/token+789==")),
+			)
+			logger.With("access_token", "accesstoken456", slog.Group("ticker", "url", tickerURL.String())).
+				WithGroup("request").
+				LogAttrs(context.Background(), slog.LevelInfo, "Request", slog.String("body", `{"api_key":"apikey123"}`))
+
+			out := buf.String()
+			for _, secret := range secrets {
+				if strings.Contains(out, secret) || strings.Contains(out, url.QueryEscape(secret)) {
+					t.Errorf("output contains %q:\n%s", secret, out)
+				}
+			}
+			if !strings.Contains(out, Redacted) {
+				t.Errorf("output has nothing redacted:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestNewWrapsOnce(t *testing.T) {
+	logger := New(nil)
+	if New(logger) != logger {
+		t.Error("New() wrapped a redacting logger again")
+	}
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"net/http"
+	"net/url"
+)
+
+// OrderMarginParam represents an order whose margin is to be computed.
+type OrderMarginParam struct {
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	Variety         string  `json:"variety"`
+	Product         string  `json:"product"`
+	OrderType       string  `json:"order_type"`
+	Quantity        float64 `json:"quantity"`
+	Price           float64 `json:"price,omitempty"`
+	TriggerPrice    float64 `json:"trigger_price,omitempty"`
+}
+
+type PNL struct {
+	Realised   float64 `json:"realised"`
+	Unrealised float64 `json:"unrealised"`
+}
+
+type GST struct {
+	IGST  float64 `json:"igst"`
+	CGST  float64 `json:"cgst"`
+	SGST  float64 `json:"sgst"`
+	Total float64 `json:"total"`
+}
+
+type Charges struct {
+	TransactionTax         float64 `json:"transaction_tax"`
+	TransactionTaxType     string  `json:"transaction_tax_type"`
+	ExchangeTurnoverCharge float64 `json:"exchange_turnover_charge"`
+	SEBITurnoverCharge     float64 `json:"sebi_turnover_charge"`
+	Brokerage              float64 `json:"brokerage"`
+	StampDuty              float64 `json:"stamp_duty"`
+	GST                    GST     `json:"gst"`
+	Total                  float64 `json:"total"`
+}
+
+// OrderMargins is the margin breakdown for a single order. In compact mode
+// only Type, Tradingsymbol, Exchange and Total are populated.
+type OrderMargins struct {
+	Type          string  `json:"type"`
+	Tradingsymbol string  `json:"tradingsymbol"`
+	Exchange      string  `json:"exchange"`
+	SPAN          float64 `json:"span"`
+	Exposure      float64 `json:"exposure"`
+	OptionPremium float64 `json:"option_premium"`
+	Additional    float64 `json:"additional"`
+	BO            float64 `json:"bo"`
+	Cash          float64 `json:"cash"`
+	VAR           float64 `json:"var"`
+	PNL           PNL     `json:"pnl"`
+	Leverage      float64 `json:"leverage"`
+	Charges       Charges `json:"charges"`
+	Total         float64 `json:"total"`
+}
+
+// BasketMargins is the margin required for a basket of orders. Initial is the
+// margin without any spread or hedge benefit and Final the margin after it.
+type BasketMargins struct {
+	Initial OrderMargins   `json:"initial"`
+	Final   OrderMargins   `json:"final"`
+	Orders  []OrderMargins `json:"orders"`
+}
+
+// HedgeBenefit returns the margin saved by placing the orders as a basket.
+func (basketMargins BasketMargins) HedgeBenefit() float64 {
+	return basketMargins.Initial.Total - basketMargins.Final.Total
+}
+
+type GetMarginParams struct {
+	OrderParams []OrderMarginParam
+	Compact     bool
+}
+
+type GetBasketParams struct {
+	OrderParams       []OrderMarginParam
+	Compact           bool
+	ConsiderPositions bool
+}
+
+func marginsURI(uri string, compact bool, considerPositions bool) string {
+	q := url.Values{}
+	if compact {
+		q.Set("mode", "compact")
+	}
+	if considerPositions {
+		q.Set("consider_positions", "true")
+	}
+	if len(q) == 0 {
+		return uri
+	}
+	return uri + "?" + q.Encode()
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOrderMargins(marginParams GetMarginParams) ([]OrderMargins, error) {
+	return kiteHttpClient.GetOrderMarginsWithContext(context.Background(), marginParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOrderMarginsWithContext(ctx context.Context, marginParams GetMarginParams) ([]OrderMargins, error) {
+	var orderMargins []OrderMargins
+
+	body, err := json.Marshal(marginParams.OrderParams)
+	if err != nil {
+		return orderMargins, httpUtils.WrapError(httpUtils.InputError, "Error encoding margin params.", 0, err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+
+	err = kiteHttpClient.doRawEnvelope(ctx, http.MethodPost, marginsURI(constants.URIOrderMargins, marginParams.Compact, false), body, headers, &orderMargins)
+	return orderMargins, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetBasketMargins(basketParams GetBasketParams) (BasketMargins, error) {
+	return kiteHttpClient.GetBasketMarginsWithContext(context.Background(), basketParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetBasketMarginsWithContext(ctx context.Context, basketParams GetBasketParams) (BasketMargins, error) {
+	var basketMargins BasketMargins
+
+	body, err := json.Marshal(basketParams.OrderParams)
+	if err != nil {
+		return basketMargins, httpUtils.WrapError(httpUtils.InputError, "Error encoding margin params.", 0, err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+
+	err = kiteHttpClient.doRawEnvelope(ctx, http.MethodPost, marginsURI(constants.URIBasketMargins, basketParams.Compact, basketParams.ConsiderPositions), body, headers, &basketMargins)
+	return basketMargins, err
+}
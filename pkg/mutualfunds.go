@@ -0,0 +1,300 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/models"
+	"github.com/google/go-querystring/query"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type MFHolding struct {
+	Folio         string  `json:"folio"`
+	Fund          string  `json:"fund"`
+	Tradingsymbol string  `json:"tradingsymbol"`
+	AveragePrice  float64 `json:"average_price"`
+	LastPrice     float64 `json:"last_price"`
+	LastPriceDate string  `json:"last_price_date"`
+	PnL           float64 `json:"pnl"`
+	Quantity      float64 `json:"quantity"`
+}
+
+type MFHoldings []MFHolding
+
+// MFTrade is a single allotment that makes up an MF holding.
+type MFTrade struct {
+	Fund              string      `json:"fund"`
+	Tradingsymbol     string      `json:"tradingsymbol"`
+	AveragePrice      float64     `json:"average_price"`
+	Variety           string      `json:"variety"`
+	ExchangeTimestamp models.Time `json:"exchange_timestamp"`
+	Amount            float64     `json:"amount"`
+	Folio             string      `json:"folio"`
+	Quantity          float64     `json:"quantity"`
+}
+
+type MFHoldingBreakdown []MFTrade
+
+type MFOrder struct {
+	OrderID           string      `json:"order_id"`
+	ExchangeOrderID   string      `json:"exchange_order_id"`
+	Tradingsymbol     string      `json:"tradingsymbol"`
+	Status            string      `json:"status"`
+	StatusMessage     string      `json:"status_message"`
+	Folio             string      `json:"folio"`
+	Fund              string      `json:"fund"`
+	OrderTimestamp    models.Time `json:"order_timestamp"`
+	ExchangeTimestamp models.Time `json:"exchange_timestamp"`
+	SettlementID      string      `json:"settlement_id"`
+
+	TransactionType string  `json:"transaction_type"`
+	Variety         string  `json:"variety"`
+	PurchaseType    string  `json:"purchase_type"`
+	Quantity        float64 `json:"quantity"`
+	Amount          float64 `json:"amount"`
+	LastPrice       float64 `json:"last_price"`
+	AveragePrice    float64 `json:"average_price"`
+	PlacedBy        string  `json:"placed_by"`
+	Tag             string  `json:"tag"`
+}
+
+type MFOrders []MFOrder
+
+type MFSIP struct {
+	ID              string `json:"sip_id"`
+	Tradingsymbol   string `json:"tradingsymbol"`
+	FundName        string `json:"fund"`
+	DividendType    string `json:"dividend_type"`
+	TransactionType string `json:"transaction_type"`
+
+	Status               string         `json:"status"`
+	SIPType              string         `json:"sip_type"`
+	Created              models.Time    `json:"created"`
+	Frequency            string         `json:"frequency"`
+	InstalmentAmount     float64        `json:"instalment_amount"`
+	Instalments          int            `json:"instalments"`
+	LastInstalment       models.Time    `json:"last_instalment"`
+	PendingInstalments   int            `json:"pending_instalments"`
+	InstalmentDay        int            `json:"instalment_day"`
+	CompletedInstalments int            `json:"completed_instalments"`
+	NextInstalment       string         `json:"next_instalment"`
+	TriggerPrice         float64        `json:"trigger_price"`
+	StepUp               map[string]int `json:"step_up"`
+	Tag                  string         `json:"tag"`
+}
+
+type MFSIPs []MFSIP
+
+// MFOrderParams places a purchase by Amount or a redemption by Quantity.
+type MFOrderParams struct {
+	Tradingsymbol   string  `url:"tradingsymbol"`
+	TransactionType string  `url:"transaction_type"`
+	Quantity        float64 `url:"quantity,omitempty"`
+	Amount          float64 `url:"amount,omitempty"`
+	Tag             string  `url:"tag,omitempty"`
+}
+
+type MFSIPParams struct {
+	Tradingsymbol string  `url:"tradingsymbol"`
+	Amount        float64 `url:"amount"`
+	Instalments   int     `url:"instalments"`
+	Frequency     string  `url:"frequency"`
+	InstalmentDay int     `url:"instalment_day,omitempty"`
+	InitialAmount float64 `url:"initial_amount,omitempty"`
+	TriggerPrice  float64 `url:"trigger_price,omitempty"`
+	StepUp        string  `url:"step_up,omitempty"`
+	SIPType       string  `url:"sip_type,omitempty"`
+	Tag           string  `url:"tag,omitempty"`
+}
+
+type MFSIPModifyParams struct {
+	Amount        float64 `url:"amount,omitempty"`
+	Frequency     string  `url:"frequency,omitempty"`
+	InstalmentDay int     `url:"instalment_day,omitempty"`
+	Instalments   int     `url:"instalments,omitempty"`
+	StepUp        string  `url:"step_up,omitempty"`
+	Status        string  `url:"status,omitempty"`
+}
+
+type MFOrderResponse struct {
+	OrderID string `json:"order_id"`
+}
+
+// MFSIPResponse carries the SIP id and, when an initial amount was given, the
+// id of the order placed for it.
+type MFSIPResponse struct {
+	OrderID *string `json:"order_id"`
+	SIPID   string  `json:"sip_id"`
+}
+
+type MFAllottedISINs []string
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrders() (MFOrders, error) {
+	return kiteHttpClient.GetMFOrdersWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrdersWithContext(ctx context.Context) (MFOrders, error) {
+	var orders MFOrders
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetMFOrders, nil, nil, &orders)
+	return orders, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrdersByDate(fromDate time.Time, toDate time.Time) (MFOrders, error) {
+	return kiteHttpClient.GetMFOrdersByDateWithContext(context.Background(), fromDate, toDate)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrdersByDateWithContext(ctx context.Context, fromDate time.Time, toDate time.Time) (MFOrders, error) {
+	var orders MFOrders
+
+	if toDate.Before(fromDate) {
+		return orders, httpUtils.NewErrorHelper(httpUtils.InputError, "MF orders `to` date is before `from` date", nil)
+	}
+
+	params := url.Values{}
+	params.Set("from", fromDate.Format("2006-01-02"))
+	params.Set("to", toDate.Format("2006-01-02"))
+
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetMFOrders, params, nil, &orders)
+	return orders, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrderInfo(orderID string) (MFOrder, error) {
+	return kiteHttpClient.GetMFOrderInfoWithContext(context.Background(), orderID)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFOrderInfoWithContext(ctx context.Context, orderID string) (MFOrder, error) {
+	var order MFOrder
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetMFOrderInfo, orderID), nil, nil, &order)
+	return order, err
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceMFOrder(orderParams MFOrderParams) (MFOrderResponse, error) {
+	return kiteHttpClient.PlaceMFOrderWithContext(context.Background(), orderParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceMFOrderWithContext(ctx context.Context, orderParams MFOrderParams) (MFOrderResponse, error) {
+	var (
+		orderResponse MFOrderResponse
+		params        url.Values
+		err           error
+	)
+
+	if params, err = query.Values(orderParams); err != nil {
+		return orderResponse, httpUtils.WrapError(httpUtils.InputError, "Error decoding MF order params.", 0, err)
+	}
+
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIPlaceMFOrder, params, nil, &orderResponse)
+	return orderResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) CancelMFOrder(orderID string) (MFOrderResponse, error) {
+	return kiteHttpClient.CancelMFOrderWithContext(context.Background(), orderID)
+}
+
+func (kiteHttpClient *KiteHttpClient) CancelMFOrderWithContext(ctx context.Context, orderID string) (MFOrderResponse, error) {
+	var orderResponse MFOrderResponse
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(constants.URICancelMFOrder, orderID), nil, nil, &orderResponse)
+	return orderResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFSIPs() (MFSIPs, error) {
+	return kiteHttpClient.GetMFSIPsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFSIPsWithContext(ctx context.Context) (MFSIPs, error) {
+	var sips MFSIPs
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetMFSIPs, nil, nil, &sips)
+	return sips, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFSIPInfo(sipID string) (MFSIP, error) {
+	return kiteHttpClient.GetMFSIPInfoWithContext(context.Background(), sipID)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFSIPInfoWithContext(ctx context.Context, sipID string) (MFSIP, error) {
+	var sip MFSIP
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetMFSIPInfo, sipID), nil, nil, &sip)
+	return sip, err
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceMFSIP(sipParams MFSIPParams) (MFSIPResponse, error) {
+	return kiteHttpClient.PlaceMFSIPWithContext(context.Background(), sipParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceMFSIPWithContext(ctx context.Context, sipParams MFSIPParams) (MFSIPResponse, error) {
+	var (
+		sipResponse MFSIPResponse
+		params      url.Values
+		err         error
+	)
+
+	if params, err = query.Values(sipParams); err != nil {
+		return sipResponse, httpUtils.WrapError(httpUtils.InputError, "Error decoding MF SIP params.", 0, err)
+	}
+
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIPlaceMFSIP, params, nil, &sipResponse)
+	return sipResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) ModifyMFSIP(sipID string, sipParams MFSIPModifyParams) (MFSIPResponse, error) {
+	return kiteHttpClient.ModifyMFSIPWithContext(context.Background(), sipID, sipParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) ModifyMFSIPWithContext(ctx context.Context, sipID string, sipParams MFSIPModifyParams) (MFSIPResponse, error) {
+	var (
+		sipResponse MFSIPResponse
+		params      url.Values
+		err         error
+	)
+
+	if params, err = query.Values(sipParams); err != nil {
+		return sipResponse, httpUtils.WrapError(httpUtils.InputError, "Error decoding MF SIP params.", 0, err)
+	}
+
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(constants.URIModifyMFSIP, sipID), params, nil, &sipResponse)
+	return sipResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) CancelMFSIP(sipID string) (MFSIPResponse, error) {
+	return kiteHttpClient.CancelMFSIPWithContext(context.Background(), sipID)
+}
+
+func (kiteHttpClient *KiteHttpClient) CancelMFSIPWithContext(ctx context.Context, sipID string) (MFSIPResponse, error) {
+	var sipResponse MFSIPResponse
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(constants.URICancelMFSIP, sipID), nil, nil, &sipResponse)
+	return sipResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFHoldings() (MFHoldings, error) {
+	return kiteHttpClient.GetMFHoldingsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFHoldingsWithContext(ctx context.Context) (MFHoldings, error) {
+	var holdings MFHoldings
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetMFHoldings, nil, nil, &holdings)
+	return holdings, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFHoldingInfo(isin string) (MFHoldingBreakdown, error) {
+	return kiteHttpClient.GetMFHoldingInfoWithContext(context.Background(), isin)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFHoldingInfoWithContext(ctx context.Context, isin string) (MFHoldingBreakdown, error) {
+	var breakdown MFHoldingBreakdown
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetMFHoldingInfo, isin), nil, nil, &breakdown)
+	return breakdown, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFAllottedISINs() (MFAllottedISINs, error) {
+	return kiteHttpClient.GetMFAllottedISINsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFAllottedISINsWithContext(ctx context.Context) (MFAllottedISINs, error) {
+	var isins MFAllottedISINs
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetAllotedISINs, nil, nil, &isins)
+	return isins, err
+}
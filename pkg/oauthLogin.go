@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultRedirectPort is the port of the redirect URL commonly registered
+	// for Kite Connect apps, http://127.0.0.1:5000/.
+	DefaultRedirectPort     = 5000
+	redirectShutdownTimeout = 5 * time.Second
+)
+
+// OAuthLoginConfig configures OAuthLogin. The redirect URL of the Kite Connect
+// app must point at the listener, e.g. http://127.0.0.1:5000/ for Port
+// DefaultRedirectPort.
+type OAuthLoginConfig struct {
+	APISecret string
+
+	// Host and Port the redirect listener binds, 127.0.0.1 by default. Port 0
+	// picks a free port. The redirect URL served is printed to Output.
+	Host string
+	Port int
+
+	// OpenBrowser opens the login URL in the default browser. It's always
+	// printed to Output, os.Stderr by default.
+	OpenBrowser bool
+	Output      io.Writer
+
+	// Store persists the session when set.
+	Store SessionStore
+}
+
+// loginRedirect is a login redirect received by the listener. Its outcome is
+// sent back on done so that the browser is only told about a successful login
+// once the session is generated.
+type loginRedirect struct {
+	requestToken string
+	err          error
+	done         chan error
+}
+
+// OAuthLogin completes the Kite Connect login: it listens for the redirect,
+// shows the login URL, exchanges the request token of a successful login for
+// a session and persists it. It returns once the session is generated, the
+// login fails, the listener fails or ctx is done. The client's access token is
+// set to the new session's.
+func (kiteHttpClient *KiteHttpClient) OAuthLogin(ctx context.Context, config OAuthLoginConfig) (UserSession, error) {
+	var session UserSession
+	if config.APISecret == "" {
+		return session, httpUtils.NewErrorHelper(httpUtils.InputError, "API secret is required", nil)
+	}
+
+	host := config.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(config.Port)))
+	if err != nil {
+		return session, fmt.Errorf("error starting the redirect listener: %w", err)
+	}
+	redirectURL := "http://" + listener.Addr().String() + "/"
+
+	var (
+		redirects = make(chan loginRedirect, 1)
+		serveErrs = make(chan error, 1)
+		stopped   = make(chan struct{})
+	)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("status") {
+			// Browsers also ask for a favicon and the like.
+			http.NotFound(w, r)
+			return
+		}
+
+		redirect := loginRedirect{done: make(chan error, 1)}
+		switch status, requestToken := query.Get("status"), query.Get("request_token"); {
+		case status != "success":
+			redirect.err = httpUtils.NewErrorHelper(httpUtils.UserError, fmt.Sprintf("Login failed with status %q", status), nil)
+		case requestToken == "":
+			redirect.err = httpUtils.NewErrorHelper(httpUtils.InputError, "Login redirect has no request token", nil)
+		default:
+			redirect.requestToken = requestToken
+		}
+
+		select {
+		case redirects <- redirect:
+		default:
+			http.Error(w, "Login already completed.", http.StatusConflict)
+			return
+		}
+		if redirect.err != nil {
+			http.Error(w, redirect.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		select {
+		case err = <-redirect.done:
+		case <-stopped:
+			err = errors.New("login aborted")
+		}
+		if err != nil {
+			http.Error(w, "Login failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "Login successful, you can close this window.\n")
+	})}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			serveErrs <- err
+		}
+	}()
+	defer func() {
+		close(stopped)
+		// Let the browser receive its response before going away.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), redirectShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	loginURL := kiteHttpClient.GetLoginURL()
+	output := config.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	fmt.Fprintf(output, "Listening for the login redirect at %s\nLog in to Kite at:\n%s\n", redirectURL, loginURL)
+	if config.OpenBrowser {
+		if err := openBrowser(loginURL); err != nil {
+			kiteHttpClient.log().Warn("Unable to open the browser", "error", err)
+		}
+	}
+
+	var redirect loginRedirect
+	select {
+	case <-ctx.Done():
+		return session, ctx.Err()
+	case err := <-serveErrs:
+		return session, fmt.Errorf("error serving the redirect listener: %w", err)
+	case redirect = <-redirects:
+	}
+	if redirect.err != nil {
+		return session, redirect.err
+	}
+
+	session, err = kiteHttpClient.completeOAuthLogin(ctx, redirect.requestToken, config)
+	redirect.done <- err
+	return session, err
+}
+
+// completeOAuthLogin exchanges the request token for a session and persists it.
+func (kiteHttpClient *KiteHttpClient) completeOAuthLogin(ctx context.Context, requestToken string, config OAuthLoginConfig) (UserSession, error) {
+	session, err := kiteHttpClient.GenerateSessionWithContext(ctx, requestToken, config.APISecret)
+	if err != nil {
+		return session, err
+	}
+	if config.Store != nil {
+		if err := config.Store.Save(session); err != nil {
+			return session, fmt.Errorf("error saving session: %w", err)
+		}
+	}
+	return session, nil
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("xdg-open", url)
+	default:
+		return errors.New("unsupported platform " + runtime.GOOS)
+	}
+	return cmd.Start()
+}
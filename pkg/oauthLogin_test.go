@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+)
+
+const testAPISecret = "secret"
+
+// newSessionServer starts a stand-in for the Kite session endpoint issuing the
+// access token access for the request token request.
+func newSessionServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("key"+"request"+testAPISecret)))
+		if r.Method != http.MethodPost || r.URL.Path != "/session/token" || r.FormValue("checksum") != checksum {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"status":"error","error_type":"TokenException","message":"Invalid checksum"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"user_id":%q,"access_token":"access"}}`, testUserID)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type oauthLoginResult struct {
+	session UserSession
+	err     error
+}
+
+// startOAuthLogin runs OAuthLogin on a free port and returns the redirect URL
+// it listens at.
+func startOAuthLogin(t *testing.T, ctx context.Context, client *KiteHttpClient, config OAuthLoginConfig) (string, <-chan oauthLoginResult) {
+	t.Helper()
+
+	output, printed := io.Pipe()
+	config.Output = printed
+	results := make(chan oauthLoginResult, 1)
+	go func() {
+		session, err := client.OAuthLogin(ctx, config)
+		printed.Close()
+		results <- oauthLoginResult{session, err}
+	}()
+
+	lines := bufio.NewScanner(output)
+	for lines.Scan() {
+		if redirectURL, ok := strings.CutPrefix(lines.Text(), "Listening for the login redirect at "); ok {
+			go io.Copy(io.Discard, output)
+			return redirectURL, results
+		}
+	}
+	t.Fatal("OAuthLogin didn't print its redirect URL")
+	return "", nil
+}
+
+func TestOAuthLogin(t *testing.T) {
+	server := newSessionServer(t)
+	newClient := func(t *testing.T) *KiteHttpClient {
+		t.Helper()
+		client, err := KiteConnect(WithAPIKey("key"), WithBaseURL(server.URL), WithRateLimiter(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	redirect := func(t *testing.T, redirectURL, query string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(redirectURL + "?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		client := newClient(t)
+		var saved []UserSession
+		store := SessionStoreFunc(func(session UserSession) error {
+			saved = append(saved, session)
+			return nil
+		})
+
+		redirectURL, results := startOAuthLogin(t, context.Background(), client, OAuthLoginConfig{APISecret: testAPISecret, Store: store})
+		if strings.HasSuffix(redirectURL, ":0/") {
+			t.Errorf("redirect URL %s has no port", redirectURL)
+		}
+		if code, body := redirect(t, redirectURL, "status=success&request_token=request"); code != http.StatusOK || !strings.Contains(body, "successful") {
+			t.Errorf("browser got %d %q, want the login to succeed", code, body)
+		}
+
+		result := <-results
+		if result.err != nil || result.session.AccessToken != "access" || client.GetAccessToken() != "access" {
+			t.Fatalf("OAuthLogin() = %+v, %v, want the access token set", result.session, result.err)
+		}
+		if len(saved) != 1 || saved[0].UserID != testUserID {
+			t.Errorf("saved sessions %+v, want the session once", saved)
+		}
+	})
+
+	t.Run("failed exchange", func(t *testing.T) {
+		redirectURL, results := startOAuthLogin(t, context.Background(), newClient(t), OAuthLoginConfig{APISecret: "wrong"})
+		if code, body := redirect(t, redirectURL, "status=success&request_token=request"); code == http.StatusOK || strings.Contains(body, "successful") {
+			t.Errorf("browser got %d %q, want an error page", code, body)
+		}
+		if result := <-results; !errors.Is(result.err, httpUtils.ErrTokenExpired) {
+			t.Errorf("OAuthLogin() error = %v, want %v", result.err, httpUtils.ErrTokenExpired)
+		}
+	})
+
+	t.Run("cancelled login", func(t *testing.T) {
+		redirectURL, results := startOAuthLogin(t, context.Background(), newClient(t), OAuthLoginConfig{APISecret: testAPISecret})
+		if code, _ := redirect(t, redirectURL, "status=cancelled"); code != http.StatusBadRequest {
+			t.Errorf("browser got %d, want %d", code, http.StatusBadRequest)
+		}
+		if result := <-results; !errors.Is(result.err, httpUtils.ErrUser) {
+			t.Errorf("OAuthLogin() error = %v, want %v", result.err, httpUtils.ErrUser)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, results := startOAuthLogin(t, ctx, newClient(t), OAuthLoginConfig{APISecret: testAPISecret})
+		if result := <-results; !errors.Is(result.err, context.DeadlineExceeded) {
+			t.Errorf("OAuthLogin() error = %v, want %v", result.err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("port taken", func(t *testing.T) {
+		taken := httptest.NewServer(http.NotFoundHandler())
+		defer taken.Close()
+		port := taken.Listener.Addr().(*net.TCPAddr).Port
+		if _, err := newClient(t).OAuthLogin(context.Background(), OAuthLoginConfig{APISecret: testAPISecret, Port: port, Output: io.Discard}); err == nil {
+			t.Error("OAuthLogin() on a taken port succeeded")
+		}
+	})
+}
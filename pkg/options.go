@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	httpUtils2 "github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithBackend selects the backend the client talks to. Its base URI and auth
+// scheme are used unless WithBaseURL or WithAuthMode are given.
+func WithBackend(backend Backend) Option {
+	return func(options *clientOptions) error {
+		if !backend.valid() {
+			return fmt.Errorf("invalid backend %s", backend)
+		}
+		options.backend = backend
+		return nil
+	}
+}
+
+// WithBaseURL sets the root URL of the REST API, e.g. for a mock server.
+func WithBaseURL(baseURL string) Option {
+	return func(options *clientOptions) error {
+		if err := validateURL(baseURL, "http", "https"); err != nil {
+			return fmt.Errorf("invalid base URL: %w", err)
+		}
+		options.baseURI = baseURL
+		return nil
+	}
+}
+
+func WithAPIKey(apiKey string) Option {
+	return func(options *clientOptions) error {
+		options.apiKey = apiKey
+		return nil
+	}
+}
+
+func WithEncToken(encToken string) Option {
+	return func(options *clientOptions) error {
+		options.encToken = encToken
+		return nil
+	}
+}
+
+func WithAccessToken(accessToken string) Option {
+	return func(options *clientOptions) error {
+		options.accessToken = accessToken
+		return nil
+	}
+}
+
+func WithAuthMode(authMode AuthMode) Option {
+	return func(options *clientOptions) error {
+		switch authMode {
+		case AuthModeAuto, AuthModeEncToken, AuthModeAccessToken:
+			options.authMode = authMode
+			return nil
+		}
+		return fmt.Errorf("invalid auth mode %d", authMode)
+	}
+}
+
+// WithTransport sets the transport requests are sent through. It must be an
+// *http.Transport when combined with WithProxy or WithTLSConfig.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(options *clientOptions) error {
+		if transport == nil {
+			return errors.New("transport can't be nil")
+		}
+		options.transport = transport
+		return nil
+	}
+}
+
+// WithProxy sends requests through an http, https or socks5 proxy.
+func WithProxy(proxyURL string) Option {
+	return func(options *clientOptions) error {
+		if err := validateURL(proxyURL, "http", "https", "socks5"); err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		options.proxy, _ = url.Parse(proxyURL)
+		return nil
+	}
+}
+
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(options *clientOptions) error {
+		if tlsConfig == nil {
+			return errors.New("TLS config can't be nil")
+		}
+		options.tlsConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a single request attempt.
+func WithTimeout(timeout time.Duration) Option {
+	return func(options *clientOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive, got %s", timeout)
+		}
+		options.timeout = timeout
+		return nil
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(options *clientOptions) error {
+		if userAgent == "" {
+			return errors.New("user agent can't be empty")
+		}
+		options.userAgent = userAgent
+		return nil
+	}
+}
+
+func WithDebug(debug bool) Option {
+	return func(options *clientOptions) error {
+		options.debug = debug
+		return nil
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(options *clientOptions) error {
+		options.logger = logger
+		return nil
+	}
+}
+
+// WithRateLimiter replaces the default rate limiter. A nil rate limiter
+// disables client-side throttling and order caps.
+func WithRateLimiter(rateLimiter *RateLimiter) Option {
+	return func(options *clientOptions) error {
+		options.rateLimiter = rateLimiter
+		return nil
+	}
+}
+
+func WithRetryPolicy(retryPolicy RetryPolicy) Option {
+	return func(options *clientOptions) error {
+		switch {
+		case retryPolicy.InitialBackoff < 0 || retryPolicy.MaxBackoff < 0 || retryPolicy.MaxRetryAfter < 0:
+			return errors.New("retry policy backoffs can't be negative")
+		case retryPolicy.Multiplier < 0:
+			return fmt.Errorf("retry policy multiplier can't be negative, got %g", retryPolicy.Multiplier)
+		case retryPolicy.Jitter < 0 || retryPolicy.Jitter > 1:
+			return fmt.Errorf("retry policy jitter must be between 0 and 1, got %g", retryPolicy.Jitter)
+		}
+		options.retryPolicy = retryPolicy
+		return nil
+	}
+}
+
+// WithMiddleware appends middlewares to the chain requests are sent through.
+func WithMiddleware(middlewares ...httpUtils2.Middleware) Option {
+	return func(options *clientOptions) error {
+		options.middlewares = append(options.middlewares, middlewares...)
+		return nil
+	}
+}
+
+func WithRecorder(recorder instrumentation.Recorder) Option {
+	return func(options *clientOptions) error {
+		options.recorder = recorder
+		return nil
+	}
+}
+
+func WithTracer(tracer instrumentation.Tracer) Option {
+	return func(options *clientOptions) error {
+		options.tracer = tracer
+		return nil
+	}
+}
+
+// validateURL checks that rawURL is absolute with one of the schemes.
+func validateURL(rawURL string, schemes ...string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", rawURL)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q must use one of the schemes %v", rawURL, schemes)
+}
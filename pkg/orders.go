@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
 	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
@@ -101,43 +102,51 @@ type Trade struct {
 
 type Trades []Trade
 
-func (kiteHttpClient *KiteHttpClient) GetOrders() Orders {
+func (kiteHttpClient *KiteHttpClient) GetOrders() (Orders, error) {
+	return kiteHttpClient.GetOrdersWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOrdersWithContext(ctx context.Context) (Orders, error) {
 	var orders Orders
-	err := kiteHttpClient.doEnvelope(http.MethodGet, constants.URIGetOrders, nil, nil, &orders)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orders
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetOrders, nil, nil, &orders)
+	return orders, err
 }
 
-func (kiteHttpClient *KiteHttpClient) GetTrades() Trades {
+func (kiteHttpClient *KiteHttpClient) GetTrades() (Trades, error) {
+	return kiteHttpClient.GetTradesWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetTradesWithContext(ctx context.Context) (Trades, error) {
 	var trades Trades
-	err := kiteHttpClient.doEnvelope(http.MethodGet, constants.URIGetTrades, nil, nil, &trades)
-	if err != nil {
-		panic(err.Error())
-	}
-	return trades
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetTrades, nil, nil, &trades)
+	return trades, err
 }
 
-func (kiteHttpClient *KiteHttpClient) GetOrderHistory(OrderID string) []Order {
+func (kiteHttpClient *KiteHttpClient) GetOrderHistory(OrderID string) ([]Order, error) {
+	return kiteHttpClient.GetOrderHistoryWithContext(context.Background(), OrderID)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOrderHistoryWithContext(ctx context.Context, OrderID string) ([]Order, error) {
 	var orderHistory []Order
-	err := kiteHttpClient.doEnvelope(http.MethodGet, fmt.Sprintf(constants.URIGetOrderHistory, OrderID), nil, nil, &orderHistory)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orderHistory
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetOrderHistory, OrderID), nil, nil, &orderHistory)
+	return orderHistory, err
 }
 
-func (kiteHttpClient *KiteHttpClient) GetOrderTrades(OrderID string) []Trade {
+func (kiteHttpClient *KiteHttpClient) GetOrderTrades(OrderID string) ([]Trade, error) {
+	return kiteHttpClient.GetOrderTradesWithContext(context.Background(), OrderID)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOrderTradesWithContext(ctx context.Context, OrderID string) ([]Trade, error) {
 	var orderTrades []Trade
-	err := kiteHttpClient.doEnvelope(http.MethodGet, fmt.Sprintf(constants.URIGetOrderTrades, OrderID), nil, nil, &orderTrades)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orderTrades
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetOrderTrades, OrderID), nil, nil, &orderTrades)
+	return orderTrades, err
 }
 
-func (kiteHttpClient *KiteHttpClient) PlaceOrder(variety string, orderParams OrderParams) OrderResponse {
+func (kiteHttpClient *KiteHttpClient) PlaceOrder(variety string, orderParams OrderParams) (OrderResponse, error) {
+	return kiteHttpClient.PlaceOrderWithContext(context.Background(), variety, orderParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) PlaceOrderWithContext(ctx context.Context, variety string, orderParams OrderParams) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -145,17 +154,18 @@ func (kiteHttpClient *KiteHttpClient) PlaceOrder(variety string, orderParams Ord
 	)
 
 	if params, err = query.Values(orderParams); err != nil {
-		panic(httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return orderResponse, httpUtils.WrapError(httpUtils.InputError, "Error decoding order params.", 0, err)
 	}
 
-	err = kiteHttpClient.doEnvelope(http.MethodPost, fmt.Sprintf(constants.URIPlaceOrder, variety), params, nil, &orderResponse)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orderResponse
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPost, fmt.Sprintf(constants.URIPlaceOrder, variety), params, nil, &orderResponse)
+	return orderResponse, err
 }
 
-func (kiteHttpClient *KiteHttpClient) ModifyOrder(variety string, orderID string, orderParams OrderParams) OrderResponse {
+func (kiteHttpClient *KiteHttpClient) ModifyOrder(variety string, orderID string, orderParams OrderParams) (OrderResponse, error) {
+	return kiteHttpClient.ModifyOrderWithContext(context.Background(), variety, orderID, orderParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) ModifyOrderWithContext(ctx context.Context, variety string, orderID string, orderParams OrderParams) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -163,17 +173,18 @@ func (kiteHttpClient *KiteHttpClient) ModifyOrder(variety string, orderID string
 	)
 
 	if params, err = query.Values(orderParams); err != nil {
-		panic(httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return orderResponse, httpUtils.WrapError(httpUtils.InputError, "Error decoding order params.", 0, err)
 	}
 
-	err = kiteHttpClient.doEnvelope(http.MethodPut, fmt.Sprintf(constants.URIModifyOrder, variety, orderID), params, nil, &orderResponse)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orderResponse
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodPut, fmt.Sprintf(constants.URIModifyOrder, variety, orderID), params, nil, &orderResponse)
+	return orderResponse, err
 }
 
-func (kiteHttpClient *KiteHttpClient) CancelOrder(variety string, orderID string, parentOrderID *string) OrderResponse {
+func (kiteHttpClient *KiteHttpClient) CancelOrder(variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
+	return kiteHttpClient.CancelOrderWithContext(context.Background(), variety, orderID, parentOrderID)
+}
+
+func (kiteHttpClient *KiteHttpClient) CancelOrderWithContext(ctx context.Context, variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
 	var (
 		orderResponse OrderResponse
 		params        url.Values
@@ -181,17 +192,18 @@ func (kiteHttpClient *KiteHttpClient) CancelOrder(variety string, orderID string
 
 	if parentOrderID != nil {
 		// initialize the params map first
-		params := url.Values{}
+		params = url.Values{}
 		params.Add("parent_order_id", *parentOrderID)
 	}
 
-	err := kiteHttpClient.doEnvelope(http.MethodDelete, fmt.Sprintf(constants.URICancelOrder, variety, orderID), params, nil, &orderResponse)
-	if err != nil {
-		panic(err.Error())
-	}
-	return orderResponse
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, fmt.Sprintf(constants.URICancelOrder, variety, orderID), params, nil, &orderResponse)
+	return orderResponse, err
+}
+
+func (kiteHttpClient *KiteHttpClient) ExitOrder(variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
+	return kiteHttpClient.ExitOrderWithContext(context.Background(), variety, orderID, parentOrderID)
 }
 
-func (kiteHttpClient *KiteHttpClient) ExitOrder(variety string, orderID string, parentOrderID *string) OrderResponse {
-	return kiteHttpClient.CancelOrder(variety, orderID, parentOrderID)
+func (kiteHttpClient *KiteHttpClient) ExitOrderWithContext(ctx context.Context, variety string, orderID string, parentOrderID *string) (OrderResponse, error) {
+	return kiteHttpClient.CancelOrderWithContext(ctx, variety, orderID, parentOrderID)
 }
@@ -0,0 +1,234 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/models"
+	"github.com/google/go-querystring/query"
+	"net/http"
+	"net/url"
+)
+
+type Holding struct {
+	Tradingsymbol   string `json:"tradingsymbol"`
+	Exchange        string `json:"exchange"`
+	InstrumentToken uint32 `json:"instrument_token"`
+	ISIN            string `json:"isin"`
+	Product         string `json:"product"`
+
+	Price              float64     `json:"price"`
+	UsedQuantity       int         `json:"used_quantity"`
+	Quantity           int         `json:"quantity"`
+	T1Quantity         int         `json:"t1_quantity"`
+	RealisedQuantity   int         `json:"realised_quantity"`
+	AuthorisedQuantity int         `json:"authorised_quantity"`
+	AuthorisedDate     models.Time `json:"authorised_date"`
+	OpeningQuantity    int         `json:"opening_quantity"`
+	CollateralQuantity int         `json:"collateral_quantity"`
+	CollateralType     string      `json:"collateral_type"`
+
+	Discrepancy         bool    `json:"discrepancy"`
+	AveragePrice        float64 `json:"average_price"`
+	LastPrice           float64 `json:"last_price"`
+	ClosePrice          float64 `json:"close_price"`
+	PnL                 float64 `json:"pnl"`
+	DayChange           float64 `json:"day_change"`
+	DayChangePercentage float64 `json:"day_change_percentage"`
+}
+
+type Holdings []Holding
+
+type AuctionInstrument struct {
+	Tradingsymbol   string `json:"tradingsymbol"`
+	Exchange        string `json:"exchange"`
+	InstrumentToken uint32 `json:"instrument_token"`
+	ISIN            string `json:"isin"`
+	Product         string `json:"product"`
+	AuctionNumber   string `json:"auction_number"`
+
+	Price              float64     `json:"price"`
+	Quantity           int         `json:"quantity"`
+	T1Quantity         int         `json:"t1_quantity"`
+	RealisedQuantity   int         `json:"realised_quantity"`
+	AuthorisedQuantity int         `json:"authorised_quantity"`
+	AuthorisedDate     models.Time `json:"authorised_date"`
+	OpeningQuantity    int         `json:"opening_quantity"`
+	CollateralQuantity int         `json:"collateral_quantity"`
+	CollateralType     string      `json:"collateral_type"`
+
+	Discrepancy         bool    `json:"discrepancy"`
+	AveragePrice        float64 `json:"average_price"`
+	LastPrice           float64 `json:"last_price"`
+	ClosePrice          float64 `json:"close_price"`
+	PnL                 float64 `json:"pnl"`
+	DayChange           float64 `json:"day_change"`
+	DayChangePercentage float64 `json:"day_change_percentage"`
+}
+
+type AuctionInstruments []AuctionInstrument
+
+type Position struct {
+	Tradingsymbol   string `json:"tradingsymbol"`
+	Exchange        string `json:"exchange"`
+	InstrumentToken uint32 `json:"instrument_token"`
+	Product         string `json:"product"`
+
+	Quantity          int     `json:"quantity"`
+	OvernightQuantity int     `json:"overnight_quantity"`
+	Multiplier        float64 `json:"multiplier"`
+
+	AveragePrice float64 `json:"average_price"`
+	ClosePrice   float64 `json:"close_price"`
+	LastPrice    float64 `json:"last_price"`
+	Value        float64 `json:"value"`
+	PnL          float64 `json:"pnl"`
+	M2M          float64 `json:"m2m"`
+	Unrealised   float64 `json:"unrealised"`
+	Realised     float64 `json:"realised"`
+
+	BuyQuantity int     `json:"buy_quantity"`
+	BuyPrice    float64 `json:"buy_price"`
+	BuyValue    float64 `json:"buy_value"`
+	BuyM2MValue float64 `json:"buy_m2m"`
+
+	SellQuantity int     `json:"sell_quantity"`
+	SellPrice    float64 `json:"sell_price"`
+	SellValue    float64 `json:"sell_value"`
+	SellM2MValue float64 `json:"sell_m2m"`
+
+	DayBuyQuantity int     `json:"day_buy_quantity"`
+	DayBuyPrice    float64 `json:"day_buy_price"`
+	DayBuyValue    float64 `json:"day_buy_value"`
+
+	DaySellQuantity int     `json:"day_sell_quantity"`
+	DaySellPrice    float64 `json:"day_sell_price"`
+	DaySellValue    float64 `json:"day_sell_value"`
+}
+
+// Positions holds the net (carried forward + today) and day-only positions.
+type Positions struct {
+	Net []Position `json:"net"`
+	Day []Position `json:"day"`
+}
+
+type ConvertPositionParams struct {
+	Exchange        string `url:"exchange"`
+	Tradingsymbol   string `url:"tradingsymbol"`
+	OldProduct      string `url:"old_product"`
+	NewProduct      string `url:"new_product"`
+	PositionType    string `url:"position_type"`
+	TransactionType string `url:"transaction_type"`
+	Quantity        int    `url:"quantity"`
+}
+
+// HoldingAuthParams describes the holdings to be authorised with CDSL. An
+// empty Instruments list authorises all holdings.
+type HoldingAuthParams struct {
+	Type         string
+	TransferType string
+	ExecDate     string
+
+	Instruments []HoldingsAuthInstruments
+}
+
+type HoldingsAuthInstruments struct {
+	ISIN     string
+	Quantity float64
+}
+
+// HoldingsAuthResp carries the request id of an authorisation along with the
+// URL the user has to be redirected to for completing it on CDSL.
+type HoldingsAuthResp struct {
+	RequestID   string `json:"request_id"`
+	RedirectURL string `json:"-"`
+}
+
+func (kiteHttpClient *KiteHttpClient) GetPositions() (Positions, error) {
+	return kiteHttpClient.GetPositionsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetPositionsWithContext(ctx context.Context) (Positions, error) {
+	var positions Positions
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetPositions, nil, nil, &positions)
+	return positions, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetHoldings() (Holdings, error) {
+	return kiteHttpClient.GetHoldingsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetHoldingsWithContext(ctx context.Context) (Holdings, error) {
+	var holdings Holdings
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetHoldings, nil, nil, &holdings)
+	return holdings, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetAuctionInstruments() (AuctionInstruments, error) {
+	return kiteHttpClient.GetAuctionInstrumentsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetAuctionInstrumentsWithContext(ctx context.Context) (AuctionInstruments, error) {
+	var auctionInstruments AuctionInstruments
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIAuctionInstruments, nil, nil, &auctionInstruments)
+	return auctionInstruments, err
+}
+
+func (kiteHttpClient *KiteHttpClient) ConvertPosition(positionParams ConvertPositionParams) (bool, error) {
+	return kiteHttpClient.ConvertPositionWithContext(context.Background(), positionParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) ConvertPositionWithContext(ctx context.Context, positionParams ConvertPositionParams) (bool, error) {
+	var (
+		params url.Values
+		err    error
+	)
+
+	if positionParams.OldProduct == positionParams.NewProduct {
+		return false, httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Position is already in product `%s`", positionParams.NewProduct), nil)
+	}
+
+	if positionParams.PositionType != constants.PositionTypeDay && positionParams.PositionType != constants.PositionTypeOvernight {
+		return false, httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Invalid position type `%s`", positionParams.PositionType), nil)
+	}
+
+	if params, err = query.Values(positionParams); err != nil {
+		return false, httpUtils.WrapError(httpUtils.InputError, "Error decoding position params.", 0, err)
+	}
+
+	if err = kiteHttpClient.doEnvelope(ctx, http.MethodPut, constants.URIConvertPosition, params, nil, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (kiteHttpClient *KiteHttpClient) InitiateHoldingsAuth(authParams HoldingAuthParams) (HoldingsAuthResp, error) {
+	return kiteHttpClient.InitiateHoldingsAuthWithContext(context.Background(), authParams)
+}
+
+func (kiteHttpClient *KiteHttpClient) InitiateHoldingsAuthWithContext(ctx context.Context, authParams HoldingAuthParams) (HoldingsAuthResp, error) {
+	var resp HoldingsAuthResp
+
+	params := url.Values{}
+	if authParams.Type != "" {
+		params.Set("type", authParams.Type)
+	}
+	if authParams.TransferType != "" {
+		params.Set("transfer_type", authParams.TransferType)
+	}
+	if authParams.ExecDate != "" {
+		params.Set("exec_date", authParams.ExecDate)
+	}
+	for _, instrument := range authParams.Instruments {
+		params.Add("isin", instrument.ISIN)
+		params.Add("quantity", fmt.Sprintf("%f", instrument.Quantity))
+	}
+
+	if err := kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIInitHoldingsAuth, params, nil, &resp); err != nil {
+		return resp, err
+	}
+
+	resp.RedirectURL = fmt.Sprintf("%s/connect/portfolio/authorise/holdings/%s/%s", constants.KiteBaseURI, kiteHttpClient.apiKey, resp.RequestID)
+	return resp, nil
+}
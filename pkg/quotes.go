@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
 	httpUtils2 "github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
@@ -87,7 +88,33 @@ type Instrument struct {
 
 type Instruments []Instrument
 
-func (kiteHttpClient *KiteHttpClient) GetQuote(instruments ...string) Quote {
+type MFInstrument struct {
+	Tradingsymbol string  `csv:"tradingsymbol"`
+	Name          string  `csv:"name"`
+	LastPrice     float64 `csv:"last_price"`
+	AMC           string  `csv:"amc"`
+
+	PurchaseAllowed                 bool        `csv:"purchase_allowed"`
+	RedemptionAllowed               bool        `csv:"redemption_allowed"`
+	MinimumPurchaseAmount           float64     `csv:"minimum_purchase_amount"`
+	PurchaseAmountMultiplier        float64     `csv:"purchase_amount_multiplier"`
+	MinimumAdditionalPurchaseAmount float64     `csv:"minimum_additional_purchase_amount"`
+	MinimumRedemptionQuantity       float64     `csv:"minimum_redemption_quantity"`
+	RedemptionQuantityMultiplier    float64     `csv:"redemption_quantity_multiplier"`
+	DividendType                    string      `csv:"dividend_type"`
+	SchemeType                      string      `csv:"scheme_type"`
+	Plan                            string      `csv:"plan"`
+	SettlementType                  string      `csv:"settlement_type"`
+	LastPriceDate                   models.Time `csv:"last_price_date"`
+}
+
+type MFInstruments []MFInstrument
+
+func (kiteHttpClient *KiteHttpClient) GetQuote(instruments ...string) (Quote, error) {
+	return kiteHttpClient.GetQuoteWithContext(context.Background(), instruments...)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetQuoteWithContext(ctx context.Context, instruments ...string) (Quote, error) {
 	var (
 		err     error
 		quotes  Quote
@@ -100,18 +127,18 @@ func (kiteHttpClient *KiteHttpClient) GetQuote(instruments ...string) Quote {
 	}
 
 	if params, err = query.Values(qParams); err != nil {
-		panic(httpUtils2.NewErrorHelper(httpUtils2.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return nil, httpUtils2.WrapError(httpUtils2.InputError, "Error decoding order params.", 0, err)
 	}
 
-	err = kiteHttpClient.doEnvelope(http.MethodGet, constants.URIGetQuote, params, nil, &quotes)
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetQuote, params, nil, &quotes)
+	return quotes, err
+}
 
-	if err != nil {
-		panic(err.Error())
-	}
-	return quotes
+func (kiteHttpClient *KiteHttpClient) GetLTP(instruments ...string) (QuoteLTP, error) {
+	return kiteHttpClient.GetLTPWithContext(context.Background(), instruments...)
 }
 
-func (kiteHttpClient *KiteHttpClient) GetLTP(instruments ...string) QuoteLTP {
+func (kiteHttpClient *KiteHttpClient) GetLTPWithContext(ctx context.Context, instruments ...string) (QuoteLTP, error) {
 	var (
 		err     error
 		quotes  QuoteLTP
@@ -124,17 +151,18 @@ func (kiteHttpClient *KiteHttpClient) GetLTP(instruments ...string) QuoteLTP {
 	}
 
 	if params, err = query.Values(qParams); err != nil {
-		panic(httpUtils2.NewErrorHelper(httpUtils2.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return nil, httpUtils2.WrapError(httpUtils2.InputError, "Error decoding order params.", 0, err)
 	}
 
-	err = kiteHttpClient.doEnvelope(http.MethodGet, constants.URIGetQuote, params, nil, &quotes)
-	if err != nil {
-		panic(err.Error())
-	}
-	return quotes
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetLTP, params, nil, &quotes)
+	return quotes, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetOHLC(instruments ...string) (QuoteOHLC, error) {
+	return kiteHttpClient.GetOHLCWithContext(context.Background(), instruments...)
 }
 
-func (kiteHttpClient *KiteHttpClient) GetOHLC(instruments ...string) QuoteOHLC {
+func (kiteHttpClient *KiteHttpClient) GetOHLCWithContext(ctx context.Context, instruments ...string) (QuoteOHLC, error) {
 	var (
 		err     error
 		quotes  QuoteOHLC
@@ -147,20 +175,23 @@ func (kiteHttpClient *KiteHttpClient) GetOHLC(instruments ...string) QuoteOHLC {
 	}
 
 	if params, err = query.Values(qParams); err != nil {
-		panic(httpUtils2.NewErrorHelper(httpUtils2.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return nil, httpUtils2.WrapError(httpUtils2.InputError, "Error decoding order params.", 0, err)
 	}
 
-	err = kiteHttpClient.doEnvelope(http.MethodGet, constants.URIGetQuote, params, nil, &quotes)
-	if err != nil {
-		panic(err.Error())
-	}
-	return quotes
+	err = kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIGetOHLC, params, nil, &quotes)
+	return quotes, err
 }
 
-func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataReceived) []HistoricalData {
+// candleDecodeError reports a candle field that couldn't be decoded along with
+// the index of the candle in the response.
+func candleDecodeError(idx int, field string, val interface{}) error {
+	return httpUtils2.NewErrorHelper(httpUtils2.DataError, fmt.Sprintf("Error decoding candle %d `%s`: %v", idx, field, val), nil)
+}
+
+func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataReceived) ([]HistoricalData, error) {
 	var data []HistoricalData
 
-	for _, i := range inp.Candles {
+	for idx, i := range inp.Candles {
 		var (
 			ds     string
 			open   float64
@@ -172,30 +203,34 @@ func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataRec
 			ok     bool
 		)
 
+		if len(i) < 6 {
+			return nil, candleDecodeError(idx, "candle", i)
+		}
+
 		if ds, ok = i[0].(string); !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `date`: %v", i[0]), nil))
+			return nil, candleDecodeError(idx, "date", i[0])
 		}
 
 		if open, ok = i[1].(float64); !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `open`: %v", i[1]), nil))
+			return nil, candleDecodeError(idx, "open", i[1])
 		}
 
 		if high, ok = i[2].(float64); !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `high`: %v", i[2]), nil))
+			return nil, candleDecodeError(idx, "high", i[2])
 		}
 
 		if low, ok = i[3].(float64); !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `low`: %v", i[3]), nil))
+			return nil, candleDecodeError(idx, "low", i[3])
 		}
 
 		if close, ok = i[4].(float64); !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `close`: %v", i[4]), nil))
+			return nil, candleDecodeError(idx, "close", i[4])
 		}
 
 		// Assert volume
 		v, ok := i[5].(float64)
 		if !ok {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `volume`: %v", i[5]), nil))
+			return nil, candleDecodeError(idx, "volume", i[5])
 		}
 
 		volume = int(v)
@@ -204,7 +239,7 @@ func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataRec
 			// Assert OI
 			OIT, ok := i[6].(float64)
 			if !ok {
-				panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response `oi`: %v", i[6]), nil))
+				return nil, candleDecodeError(idx, "oi", i[6])
 			}
 			OI = int(OIT)
 		}
@@ -212,11 +247,11 @@ func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataRec
 		// Parse string to date
 		d, err := time.Parse("2006-01-02T15:04:05-0700", ds)
 		if err != nil {
-			panic(httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error decoding response: %v", err), nil))
+			return nil, candleDecodeError(idx, "date", err)
 		}
 
 		data = append(data, HistoricalData{
-			Date:   models.Time{d},
+			Date:   models.Time{Time: d},
 			Open:   open,
 			High:   high,
 			Low:    low,
@@ -226,10 +261,14 @@ func (kiteHttpClient *KiteHttpClient) formatHistoricalData(inp historicalDataRec
 		})
 	}
 
-	return data
+	return data, nil
+}
+
+func (kiteHttpClient *KiteHttpClient) GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]HistoricalData, error) {
+	return kiteHttpClient.GetHistoricalDataWithContext(context.Background(), instrumentToken, interval, fromDate, toDate, continuous, OI)
 }
 
-func (kiteHttpClient *KiteHttpClient) GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) []HistoricalData {
+func (kiteHttpClient *KiteHttpClient) GetHistoricalDataWithContext(ctx context.Context, instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]HistoricalData, error) {
 	var (
 		err       error
 		params    url.Values
@@ -252,50 +291,62 @@ func (kiteHttpClient *KiteHttpClient) GetHistoricalData(instrumentToken int, int
 	}
 
 	if params, err = query.Values(inpParams); err != nil {
-		panic(httpUtils2.NewErrorHelper(httpUtils2.InputError, fmt.Sprintf("Error decoding order params: %v", err), nil))
+		return nil, httpUtils2.WrapError(httpUtils2.InputError, "Error decoding order params.", 0, err)
 	}
 
 	var resp historicalDataReceived
-	if err := kiteHttpClient.doEnvelope(http.MethodGet, fmt.Sprintf(constants.URIGetHistorical, instrumentToken, interval), params, nil, &resp); err != nil {
-		panic(err.Error())
+	if err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIGetHistorical, instrumentToken, interval), params, nil, &resp); err != nil {
+		return nil, err
 	}
 
 	return kiteHttpClient.formatHistoricalData(resp)
 }
 
-func (kiteHttpClient *KiteHttpClient) parseInstruments(data interface{}, url string, params url.Values) error {
+func (kiteHttpClient *KiteHttpClient) parseInstruments(ctx context.Context, data interface{}, url string, params url.Values) error {
 	var (
 		err  error
 		resp httpUtils2.HTTPResponse
 	)
 
 	// Get CSV response
-	if resp, err = kiteHttpClient.do(http.MethodGet, url, params, nil); err != nil {
+	if resp, err = kiteHttpClient.do(ctx, http.MethodGet, url, params, nil); err != nil {
 		return err
 	}
 
 	// Unmarshal CSV response to instruments
 	if err = gocsv.UnmarshalBytes(resp.Body, data); err != nil {
-		return httpUtils2.NewErrorHelper(httpUtils2.GeneralError, fmt.Sprintf("Error parsing csv response: %v", err), nil)
+		return httpUtils2.WrapError(httpUtils2.DataError, "Error parsing csv response.", resp.Response.StatusCode, err)
 	}
 
 	return nil
 }
 
-func (kiteHttpClient *KiteHttpClient) GetInstruments() Instruments {
+func (kiteHttpClient *KiteHttpClient) GetInstruments() (Instruments, error) {
+	return kiteHttpClient.GetInstrumentsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetInstrumentsWithContext(ctx context.Context) (Instruments, error) {
 	var instruments Instruments
-	err := kiteHttpClient.parseInstruments(&instruments, constants.URIGetInstruments, nil)
-	if err != nil {
-		panic(err.Error())
-	}
-	return instruments
+	err := kiteHttpClient.parseInstruments(ctx, &instruments, constants.URIGetInstruments, nil)
+	return instruments, err
 }
 
-func (kiteHttpClient *KiteHttpClient) GetInstrumentsByExchange(exchange string) Instruments {
+func (kiteHttpClient *KiteHttpClient) GetInstrumentsByExchange(exchange string) (Instruments, error) {
+	return kiteHttpClient.GetInstrumentsByExchangeWithContext(context.Background(), exchange)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetInstrumentsByExchangeWithContext(ctx context.Context, exchange string) (Instruments, error) {
 	var instruments Instruments
-	err := kiteHttpClient.parseInstruments(&instruments, fmt.Sprintf(constants.URIGetInstrumentsExchange, exchange), nil)
-	if err != nil {
-		panic(err.Error())
-	}
-	return instruments
+	err := kiteHttpClient.parseInstruments(ctx, &instruments, fmt.Sprintf(constants.URIGetInstrumentsExchange, exchange), nil)
+	return instruments, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFInstruments() (MFInstruments, error) {
+	return kiteHttpClient.GetMFInstrumentsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetMFInstrumentsWithContext(ctx context.Context) (MFInstruments, error) {
+	var instruments MFInstruments
+	err := kiteHttpClient.parseInstruments(ctx, &instruments, constants.URIGetMFInstruments, nil)
+	return instruments, err
 }
@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointClass groups the endpoints that share a Kite rate limit.
+type EndpointClass string
+
+const (
+	EndpointQuote      EndpointClass = "quote"
+	EndpointHistorical EndpointClass = "historical"
+	EndpointOrder      EndpointClass = "order"
+	EndpointDefault    EndpointClass = "default"
+)
+
+var (
+	historicalURIPrefix = constants.URIGetHistorical[:strings.Index(constants.URIGetHistorical, "%")]
+	orderURIPrefix      = constants.URIGetOrders + "/"
+
+	ist = time.FixedZone("IST", 5*60*60+30*60)
+)
+
+// endpointClass derives the rate limit class of a request from its URI.
+func endpointClass(method, uri string) EndpointClass {
+	switch {
+	case strings.HasPrefix(uri, constants.URIGetQuote):
+		return EndpointQuote
+	case strings.HasPrefix(uri, historicalURIPrefix):
+		return EndpointHistorical
+	case method != http.MethodGet && strings.HasPrefix(uri, orderURIPrefix):
+		return EndpointOrder
+	}
+	return EndpointDefault
+}
+
+func isOrderPlacement(method, uri string) bool {
+	return method == http.MethodPost && strings.HasPrefix(uri, orderURIPrefix)
+}
+
+// RateLimit is a token bucket refilled at Rate requests per second holding
+// at most Burst requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// OrderCaps limits the number of orders placed per minute and per trading
+// day. Zero disables a cap.
+type OrderCaps struct {
+	PerMinute int
+	PerDay    int
+}
+
+// DefaultRateLimits are the limits Kite enforces per API key.
+func DefaultRateLimits() map[EndpointClass]RateLimit {
+	return map[EndpointClass]RateLimit{
+		EndpointQuote:      {Rate: 1, Burst: 1},
+		EndpointHistorical: {Rate: 3, Burst: 3},
+		EndpointOrder:      {Rate: 10, Burst: 10},
+		EndpointDefault:    {Rate: 10, Burst: 10},
+	}
+}
+
+// DefaultOrderCaps are the order caps Kite enforces per user.
+func DefaultOrderCaps() OrderCaps {
+	return OrderCaps{PerMinute: 200, PerDay: 3000}
+}
+
+// RateLimiter throttles requests per endpoint class, blocking callers until
+// their request can be sent, and refuses orders beyond the order caps. It is
+// safe for concurrent use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[EndpointClass]*tokenBucket
+	orders  orderCounter
+}
+
+func NewRateLimiter(limits map[EndpointClass]RateLimit, orderCaps OrderCaps) *RateLimiter {
+	rateLimiter := &RateLimiter{
+		buckets: map[EndpointClass]*tokenBucket{},
+		orders:  orderCounter{caps: orderCaps},
+	}
+	for class, limit := range limits {
+		rateLimiter.SetLimit(class, limit)
+	}
+	return rateLimiter
+}
+
+func NewDefaultRateLimiter() *RateLimiter {
+	return NewRateLimiter(DefaultRateLimits(), DefaultOrderCaps())
+}
+
+// SetLimit sets the limit of an endpoint class. Classes without a limit
+// aren't throttled.
+func (rateLimiter *RateLimiter) SetLimit(class EndpointClass, limit RateLimit) {
+	rateLimiter.mu.Lock()
+	defer rateLimiter.mu.Unlock()
+	rateLimiter.buckets[class] = newTokenBucket(limit)
+}
+
+// Wait blocks until a request of the class may be sent or ctx is done.
+func (rateLimiter *RateLimiter) Wait(ctx context.Context, class EndpointClass) error {
+	rateLimiter.mu.Lock()
+	bucket := rateLimiter.buckets[class]
+	rateLimiter.mu.Unlock()
+
+	if bucket == nil {
+		return nil
+	}
+
+	delay := bucket.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, delay); err != nil {
+		bucket.cancel()
+		return err
+	}
+	return nil
+}
+
+// ReserveOrder counts an order about to be placed, failing with
+// httpUtils.ErrRateLimited when that would exceed the order caps. The order
+// must be released when it isn't placed after all so that it doesn't count
+// toward the caps.
+func (rateLimiter *RateLimiter) ReserveOrder() (release func(), err error) {
+	now := time.Now()
+	if err := rateLimiter.orders.reserve(now); err != nil {
+		return nil, err
+	}
+	return func() { rateLimiter.orders.release(now) }, nil
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a token and returns how long the caller has to wait for it.
+// Tokens may go negative so that waiters are served in order.
+func (bucket *tokenBucket) reserve(now time.Time) time.Duration {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if bucket.rate <= 0 {
+		return 0
+	}
+
+	if !bucket.last.IsZero() {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+	}
+	bucket.last = now
+	bucket.tokens--
+
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// cancel returns a token reserved by a caller that gave up waiting.
+func (bucket *tokenBucket) cancel() {
+	bucket.mu.Lock()
+	bucket.tokens++
+	bucket.mu.Unlock()
+}
+
+type orderCounter struct {
+	mu     sync.Mutex
+	caps   OrderCaps
+	minute []time.Time
+	day    time.Time
+	today  int
+}
+
+func (counter *orderCounter) reserve(now time.Time) error {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	// Trading days roll over at midnight IST.
+	y, m, d := now.In(ist).Date()
+	if day := time.Date(y, m, d, 0, 0, 0, 0, ist); !day.Equal(counter.day) {
+		counter.day = day
+		counter.today = 0
+	}
+
+	// Drop orders older than a minute from the sliding window.
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(counter.minute) && !counter.minute[i].After(cutoff) {
+		i++
+	}
+	counter.minute = counter.minute[i:]
+
+	if counter.caps.PerDay > 0 && counter.today >= counter.caps.PerDay {
+		return orderCapError(fmt.Sprintf("Order cap of %d orders per day reached", counter.caps.PerDay))
+	}
+	if counter.caps.PerMinute > 0 && len(counter.minute) >= counter.caps.PerMinute {
+		return orderCapError(fmt.Sprintf("Order cap of %d orders per minute reached", counter.caps.PerMinute))
+	}
+
+	counter.today++
+	counter.minute = append(counter.minute, now)
+	return nil
+}
+
+// release takes back an order reserved at the given time.
+func (counter *orderCounter) release(at time.Time) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	y, m, d := at.In(ist).Date()
+	if day := time.Date(y, m, d, 0, 0, 0, 0, ist); day.Equal(counter.day) && counter.today > 0 {
+		counter.today--
+	}
+	for i := len(counter.minute) - 1; i >= 0; i-- {
+		if counter.minute[i].Equal(at) {
+			counter.minute = append(counter.minute[:i], counter.minute[i+1:]...)
+			break
+		}
+	}
+}
+
+func orderCapError(message string) error {
+	return httpUtils.NewError(httpUtils.RateLimitError, message, 0, nil)
+}
+
+func (kiteHttpClient *KiteHttpClient) SetRateLimiter(rateLimiter *RateLimiter) {
+	kiteHttpClient.rateLimiter = rateLimiter
+}
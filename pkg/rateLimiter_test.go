@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		bucket = newTokenBucket(RateLimit{Rate: 2, Burst: 2})
+		now    = time.Date(2024, 1, 2, 10, 0, 0, 0, ist)
+	)
+
+	// The burst goes out at once, then waiters queue up at the rate.
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond, time.Second} {
+		if got := bucket.reserve(now); got != want {
+			t.Errorf("reserve() #%d = %s, want %s", i+1, got, want)
+		}
+	}
+
+	// A waiter giving up hands its token to the next one.
+	bucket.cancel()
+	if got := bucket.reserve(now); got != time.Second {
+		t.Errorf("reserve() after cancel = %s, want %s", got, time.Second)
+	}
+
+	// Idle time refills the bucket up to its burst only.
+	now = now.Add(time.Minute)
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond} {
+		if got := bucket.reserve(now); got != want {
+			t.Errorf("reserve() #%d after a minute = %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	rateLimiter := NewRateLimiter(map[EndpointClass]RateLimit{EndpointQuote: {Rate: 0.001, Burst: 1}}, OrderCaps{})
+
+	if err := rateLimiter.Wait(context.Background(), EndpointDefault); err != nil {
+		t.Errorf("Wait() on an unlimited class error = %v", err)
+	}
+	if err := rateLimiter.Wait(context.Background(), EndpointQuote); err != nil {
+		t.Fatalf("Wait() within the burst error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rateLimiter.Wait(ctx, EndpointQuote); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() past the deadline error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if tokens := rateLimiter.buckets[EndpointQuote].tokens; tokens < -0.5 {
+		t.Errorf("bucket holds %.2f tokens after a cancelled Wait, want the token returned", tokens)
+	}
+}
+
+func TestOrderCounter(t *testing.T) {
+	var (
+		counter = orderCounter{caps: OrderCaps{PerMinute: 2, PerDay: 3}}
+		now     = time.Date(2024, 1, 2, 10, 0, 0, 0, ist)
+	)
+
+	reserve := func(at time.Time, wantErr bool) {
+		t.Helper()
+		err := counter.reserve(at)
+		if wantErr != (err != nil) {
+			t.Fatalf("reserve(%s) error = %v, want error %t", at.Format(time.TimeOnly), err, wantErr)
+		}
+		if err != nil && !errors.Is(err, httpUtils.ErrRateLimited) {
+			t.Fatalf("reserve(%s) error = %v, want %v", at.Format(time.TimeOnly), err, httpUtils.ErrRateLimited)
+		}
+	}
+
+	reserve(now, false)
+	reserve(now.Add(time.Second), false)
+	reserve(now.Add(2*time.Second), true)
+
+	// Released orders free their slot.
+	counter.release(now.Add(time.Second))
+	reserve(now.Add(3*time.Second), false)
+
+	// The first order leaves the window a minute later, filling the day.
+	reserve(now.Add(time.Minute+time.Second), false)
+	reserve(now.Add(2*time.Minute), true)
+
+	// The day rolls over at midnight IST.
+	midnight := time.Date(2024, 1, 3, 0, 0, 0, 0, ist)
+	reserve(midnight.Add(-time.Second), true)
+	reserve(midnight, false)
+}
+
+func TestExecuteReleasesFailedOrders(t *testing.T) {
+	client, err := KiteConnect(WithRateLimiter(NewRateLimiter(nil, OrderCaps{PerMinute: 1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		uri    = constants.URIGetOrders + "/regular"
+		failed = httpUtils.NewError(httpUtils.InputError, "Invalid quantity", http.StatusBadRequest, nil)
+		ok     = func(ctx context.Context) error { return nil }
+	)
+	if err := client.execute(context.Background(), http.MethodPost, uri, func(ctx context.Context) error { return failed }); !errors.Is(err, httpUtils.ErrInput) {
+		t.Fatalf("execute() error = %v, want %v", err, httpUtils.ErrInput)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.execute(ctx, http.MethodPost, uri, func(ctx context.Context) error { return ctx.Err() }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("execute() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	if err := client.execute(context.Background(), http.MethodPost, uri, ok); err != nil {
+		t.Fatalf("execute() after failed orders error = %v", err)
+	}
+	if err := client.execute(context.Background(), http.MethodPost, uri, ok); !errors.Is(err, httpUtils.ErrRateLimited) {
+		t.Errorf("execute() over the order cap error = %v, want %v", err, httpUtils.ErrRateLimited)
+	}
+}
+
+// TestExecuteKeepsOrdersLostInFlight checks orders failing after they were
+// sent, which may have been placed, still count toward the caps.
+func TestExecuteKeepsOrdersLostInFlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"connection lost", func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+		}},
+		{"gateway timeout", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+
+			client, err := KiteConnect(WithEncToken("enc"), WithBaseURL(server.URL), WithRateLimiter(NewRateLimiter(nil, OrderCaps{PerMinute: 1})))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			uri := constants.URIGetOrders + "/regular"
+			if err := client.doEnvelope(context.Background(), http.MethodPost, uri, nil, nil, nil); !errors.Is(err, httpUtils.ErrNetwork) {
+				t.Fatalf("POST error = %v, want %v", err, httpUtils.ErrNetwork)
+			}
+			if err := client.doEnvelope(context.Background(), http.MethodPost, uri, nil, nil, nil); !errors.Is(err, httpUtils.ErrRateLimited) {
+				t.Errorf("POST after an order lost in flight error = %v, want %v", err, httpUtils.ErrRateLimited)
+			}
+			if requests.Load() != 1 {
+				t.Errorf("server got %d orders, want 1", requests.Load())
+			}
+		})
+	}
+}
+
+func TestExecuteThrottlesReplay(t *testing.T) {
+	rateLimiter := NewRateLimiter(map[EndpointClass]RateLimit{EndpointDefault: {Rate: 0.001, Burst: 2}}, OrderCaps{})
+	client, err := KiteConnect(WithEncToken("stale"), WithRateLimiter(rateLimiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetTokenProvider(TokenProviderFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{EncToken: "fresh"}, nil
+	}))
+
+	sends := 0
+	err = client.execute(context.Background(), http.MethodGet, constants.URIUserProfile, func(ctx context.Context) error {
+		sends++
+		if sends == 1 {
+			return httpUtils.NewError(httpUtils.TokenError, "Token expired", http.StatusForbidden, nil)
+		}
+		return nil
+	})
+	if err != nil || sends != 2 {
+		t.Fatalf("execute() = %v after %d sends, want the request replayed once", err, sends)
+	}
+	if tokens := rateLimiter.buckets[EndpointDefault].tokens; tokens > 0.5 {
+		t.Errorf("bucket holds %.2f tokens after the replay, want both sends throttled", tokens)
+	}
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with a retryable error (HTTP
+// 429/502/503/504 or a transport failure such as a connection reset) are
+// retried. GET requests are always eligible, order placement only when
+// RetryOrderPlacement is set and every other request never is. A MaxAttempts
+// of 1 or less disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. A Retry-After sent by the
+	// server takes precedence over it.
+	MaxBackoff time.Duration
+	// MaxRetryAfter caps the delay a Retry-After sent by the server may ask
+	// for, so that a bogus header can't stall the call. Zero caps it at
+	// MaxBackoff.
+	MaxRetryAfter time.Duration
+	// Multiplier grows the delay after every attempt.
+	Multiplier float64
+	// Jitter randomises each delay by up to this fraction of it.
+	Jitter float64
+	// RetryOrderPlacement allows retrying order placement. Kite doesn't
+	// deduplicate orders, so a retry after a lost response may place the
+	// order twice.
+	RetryOrderPlacement bool
+	// OnRetry is invoked before sleeping for every retry.
+	OnRetry func(event RetryEvent)
+}
+
+// RetryEvent describes a retry about to be made.
+type RetryEvent struct {
+	Method  string
+	URI     string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+// DefaultRetryPolicy retries eligible requests up to twice with exponential
+// backoff starting at 250ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		MaxRetryAfter:  30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+func (kiteHttpClient *KiteHttpClient) SetRetryPolicy(retryPolicy RetryPolicy) {
+	kiteHttpClient.retryPolicy = retryPolicy
+}
+
+// allows reports whether the request may be retried under the policy.
+func (retryPolicy RetryPolicy) allows(method, uri string) bool {
+	if retryPolicy.MaxAttempts <= 1 {
+		return false
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return retryPolicy.RetryOrderPlacement && isOrderPlacement(method, uri)
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, honouring Retry-After up
+// to MaxRetryAfter.
+func (retryPolicy RetryPolicy) backoff(retry int, err error) time.Duration {
+	if delay, ok := retryAfter(err, time.Now()); ok {
+		maxDelay := retryPolicy.MaxRetryAfter
+		if maxDelay == 0 {
+			maxDelay = retryPolicy.MaxBackoff
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+		return delay
+	}
+
+	delay := float64(retryPolicy.InitialBackoff) * math.Pow(math.Max(retryPolicy.Multiplier, 1), float64(retry-1))
+	if retryPolicy.MaxBackoff > 0 && delay > float64(retryPolicy.MaxBackoff) {
+		delay = float64(retryPolicy.MaxBackoff)
+	}
+	if retryPolicy.Jitter > 0 {
+		delay += delay * retryPolicy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// retryAfter returns the delay asked for by the Retry-After header of err, in
+// seconds or as an HTTP date relative to now.
+func retryAfter(err error, now time.Time) (time.Duration, bool) {
+	var kiteErr httpUtils.Error
+	if !errors.As(err, &kiteErr) || kiteErr.Header == nil {
+		return 0, false
+	}
+
+	value := kiteErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for delay or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+)
+
+func unavailable(retryAfter string) error {
+	err := httpUtils.NewError(httpUtils.NetworkError, "Service unavailable", http.StatusServiceUnavailable, nil)
+	if retryAfter != "" {
+		err.Header = http.Header{"Retry-After": {retryAfter}}
+	}
+	return err
+}
+
+func TestRetryBackoff(t *testing.T) {
+	retryPolicy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for retry, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		if got := retryPolicy.backoff(retry+1, unavailable("")); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", retry+1, got, want)
+		}
+
+		// Jitter spreads the delay evenly around it.
+		jittered := retryPolicy
+		jittered.Jitter = 0.2
+		least, most := want, want
+		for i := 0; i < 1000; i++ {
+			got := jittered.backoff(retry+1, unavailable(""))
+			least, most = min(least, got), max(most, got)
+		}
+		if lo, hi := want*8/10, want*12/10; least < lo || most > hi || least == most {
+			t.Errorf("backoff(%d) with jitter within [%s, %s], want a spread within [%s, %s]", retry+1, least, most, lo, hi)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"none", "", 0, false},
+		{"seconds", "3", 3 * time.Second, true},
+		{"zero seconds", "0", 0, true},
+		{"negative seconds", "-1", 0, false},
+		{"date", now.Add(5 * time.Second).Format(http.TimeFormat), 5 * time.Second, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(unavailable(tt.header), now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter(%q) = %s, %t, want %s, %t", tt.header, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := retryAfter(errors.New("connection reset"), now); ok {
+		t.Error("retryAfter() of an error without a response succeeded")
+	}
+}
+
+func TestRetryAfterClamp(t *testing.T) {
+	tests := []struct {
+		name        string
+		retryPolicy RetryPolicy
+		header      string
+		want        time.Duration
+	}{
+		{"within the cap", DefaultRetryPolicy(), "10", 10 * time.Second},
+		{"over the cap", DefaultRetryPolicy(), "86400", 30 * time.Second},
+		{"capped at MaxBackoff", RetryPolicy{MaxBackoff: 2 * time.Second}, "60", 2 * time.Second},
+		{"over MaxBackoff within the cap", RetryPolicy{MaxBackoff: 2 * time.Second, MaxRetryAfter: time.Minute}, "10", 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retryPolicy.backoff(1, unavailable(tt.header)); got != tt.want {
+				t.Errorf("backoff() with Retry-After %s = %s, want %s", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAllows(t *testing.T) {
+	var (
+		placeOrder = constants.URIGetOrders + "/regular"
+		defaults   = DefaultRetryPolicy()
+		withOrders = DefaultRetryPolicy()
+		noRetries  = DefaultRetryPolicy()
+	)
+	withOrders.RetryOrderPlacement = true
+	noRetries.MaxAttempts = 1
+
+	tests := []struct {
+		method, uri string
+		// want holds the result under defaults, withOrders and noRetries.
+		want [3]bool
+	}{
+		{http.MethodGet, constants.URIGetOrders, [3]bool{true, true, false}},
+		{http.MethodHead, constants.URIGetOrders, [3]bool{true, true, false}},
+		{http.MethodPost, placeOrder, [3]bool{false, true, false}},
+		{http.MethodPost, constants.URIUserProfile, [3]bool{false, false, false}},
+		{http.MethodPut, placeOrder + "/1", [3]bool{false, false, false}},
+		{http.MethodDelete, placeOrder + "/1", [3]bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		for i, retryPolicy := range []RetryPolicy{defaults, withOrders, noRetries} {
+			if got := retryPolicy.allows(tt.method, tt.uri); got != tt.want[i] {
+				t.Errorf("policy %d allows(%s %s) = %t, want %t", i, tt.method, tt.uri, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestExecuteRetries(t *testing.T) {
+	retryPolicy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+	client, err := KiteConnect(WithRetryPolicy(retryPolicy), WithRateLimiter(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(sends *int, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			*sends++
+			return err
+		}
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		sends := 0
+		err := client.execute(context.Background(), http.MethodGet, constants.URIGetOrders, send(&sends, unavailable("")))
+		if !errors.Is(err, httpUtils.ErrNetwork) || sends != retryPolicy.MaxAttempts {
+			t.Errorf("execute() = %v after %d sends, want %v after %d", err, sends, httpUtils.ErrNetwork, retryPolicy.MaxAttempts)
+		}
+	})
+
+	t.Run("non-idempotent", func(t *testing.T) {
+		sends := 0
+		err := client.execute(context.Background(), http.MethodPost, constants.URIGetOrders+"/regular", send(&sends, unavailable("")))
+		if !errors.Is(err, httpUtils.ErrNetwork) || sends != 1 {
+			t.Errorf("execute() = %v after %d sends, want %v after 1", err, sends, httpUtils.ErrNetwork)
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		sends := 0
+		input := httpUtils.NewError(httpUtils.InputError, "Invalid quantity", http.StatusBadRequest, nil)
+		err := client.execute(context.Background(), http.MethodGet, constants.URIGetOrders, send(&sends, input))
+		if !errors.Is(err, httpUtils.ErrInput) || sends != 1 {
+			t.Errorf("execute() = %v after %d sends, want %v after 1", err, sends, httpUtils.ErrInput)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Stop while waiting for a Retry-After far in the future.
+		cancelling := retryPolicy
+		cancelling.MaxRetryAfter = time.Hour
+		cancelling.OnRetry = func(RetryEvent) { cancel() }
+		client.SetRetryPolicy(cancelling)
+		defer client.SetRetryPolicy(retryPolicy)
+
+		sends := 0
+		start := time.Now()
+		err := client.execute(ctx, http.MethodGet, constants.URIGetOrders, send(&sends, unavailable("3600")))
+		if !errors.Is(err, context.Canceled) || sends != 1 || time.Since(start) > time.Minute {
+			t.Errorf("execute() = %v after %d sends, want %v after 1", err, sends, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SessionStore persists the session obtained by a login.
+type SessionStore interface {
+	Save(session UserSession) error
+}
+
+// SessionStoreFunc adapts a callback to a SessionStore.
+type SessionStoreFunc func(session UserSession) error
+
+func (f SessionStoreFunc) Save(session UserSession) error {
+	return f(session)
+}
+
+// FileSessionStore writes the session as JSON readable by the owner only. The
+// file holds `access_token` so FileTokenProvider can pick the session up.
+func FileSessionStore(path string) SessionStore {
+	return SessionStoreFunc(func(session UserSession) error {
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding session: %w", err)
+		}
+
+		// Write to a temporary file first so readers never see a partial session.
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Chmod(0600); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	})
+}
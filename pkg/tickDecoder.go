@@ -0,0 +1,275 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// PacketError is a malformed packet of a binary frame. The valid packets of
+// the frame are delivered regardless.
+type PacketError struct {
+	// Token is the instrument token of the packet, zero when the packet is too
+	// short to carry one or couldn't be delimited.
+	Token uint32
+	// Packet holds the raw bytes of the packet, or the rest of the frame when
+	// the frame doesn't match its header.
+	Packet []byte
+	// Reason describes what's wrong with the packet.
+	Reason string
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("malformed packet for token %d: %s", e.Token, e.Reason)
+}
+
+// newPacketError returns a PacketError holding a copy of pkt, since frames
+// may be reused once parsed.
+func newPacketError(token uint32, pkt []byte, format string, args ...any) *PacketError {
+	return &PacketError{
+		Token:  token,
+		Packet: append([]byte(nil), pkt...),
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// FrameReader reads the packets of a binary ticker frame in place. It doesn't
+// allocate, so a single FrameReader and Tick can decode any number of frames:
+//
+//	var (
+//		frames pkg.FrameReader
+//		tick   models.Tick
+//	)
+//	frames.Reset(frame)
+//	for frames.Next() {
+//		if err := frames.Decode(&tick); err != nil {
+//			// Malformed packet, the next ones are still read.
+//			continue
+//		}
+//		// Use tick, it's overwritten by the next Decode.
+//	}
+//	if err := frames.Err(); err != nil {
+//		// The frame doesn't match its header.
+//	}
+//
+// Only malformed frames and packets allocate, for their *PacketError.
+type FrameReader struct {
+	frame  []byte
+	count  int
+	read   int
+	pos    int
+	packet []byte
+	err    error
+}
+
+// Reset starts reading frame. Frames shorter than the header, like
+// heartbeats, carry no packets.
+func (r *FrameReader) Reset(frame []byte) {
+	*r = FrameReader{frame: frame, pos: len(frame)}
+	if len(frame) >= 2 {
+		r.count = int(binary.BigEndian.Uint16(frame[0:2]))
+		r.pos = 2
+	}
+}
+
+// Len returns the number of packets the frame's header announces.
+func (r *FrameReader) Len() int {
+	return r.count
+}
+
+// Next advances to the next packet. It returns false once all the packets are
+// read or the frame doesn't match the packet count and lengths of its
+// headers, which Err reports.
+func (r *FrameReader) Next() bool {
+	r.packet = nil
+	if r.err != nil {
+		return false
+	}
+
+	rest := r.frame[r.pos:]
+	if r.read == r.count {
+		if len(rest) > 0 {
+			r.err = newPacketError(0, rest, "%d bytes follow the %d packets of the frame", len(rest), r.count)
+		}
+		return false
+	}
+
+	if len(rest) < 2 {
+		r.err = newPacketError(0, rest, "frame ends before packet %d of %d", r.read+1, r.count)
+		return false
+	}
+
+	pLen := int(binary.BigEndian.Uint16(rest[0:2]))
+	pkt := rest[2:]
+	if len(pkt) < pLen {
+		var tk uint32
+		if len(pkt) >= 4 {
+			tk = binary.BigEndian.Uint32(pkt[0:4])
+		}
+		r.err = newPacketError(tk, pkt, "packet %d of %d has length %d but the frame ends after %d bytes", r.read+1, r.count, pLen, len(pkt))
+		return false
+	}
+
+	r.packet = pkt[:pLen]
+	r.pos += 2 + pLen
+	r.read++
+	return true
+}
+
+// Packet returns the raw bytes of the current packet. They alias the frame.
+func (r *FrameReader) Packet() []byte {
+	return r.packet
+}
+
+// Decode decodes the current packet into tick.
+func (r *FrameReader) Decode(tick *models.Tick) error {
+	return DecodePacket(r.packet, tick)
+}
+
+// Err returns the error that stopped Next, nil when all the packets were
+// read.
+func (r *FrameReader) Err() error {
+	return r.err
+}
+
+// DecodePacket decodes a single packet into tick, overwriting all its fields.
+// It doesn't allocate unless the packet is malformed, in which case tick is
+// left untouched and a *PacketError returned.
+func DecodePacket(b []byte, tick *models.Tick) error {
+	if len(b) < 4 {
+		return newPacketError(0, b, "packet of %d bytes is too short for a token", len(b))
+	}
+
+	var (
+		tk         = binary.BigEndian.Uint32(b[0:4])
+		seg        = tk & 0xFF
+		isIndex    = seg == Indices
+		isTradable = seg != Indices
+		divisor    = priceDivisor(seg)
+	)
+
+	price := func(pos int) float64 {
+		return float64(binary.BigEndian.Uint32(b[pos:pos+4])) / divisor
+	}
+
+	switch len(b) {
+	// Mode LTP parsing
+	case modeLTPLength:
+		*tick = models.Tick{
+			Mode:            string(ModeLTP),
+			InstrumentToken: tk,
+			IsTradable:      isTradable,
+			IsIndex:         isIndex,
+			LastPrice:       price(4),
+		}
+
+	// Parse index mode full and mode quote data
+	case modeQuoteIndexPacketLength, modeFullIndexLength:
+		var (
+			lastPrice  = price(4)
+			closePrice = price(20)
+		)
+
+		*tick = models.Tick{
+			Mode:            string(ModeQuote),
+			InstrumentToken: tk,
+			IsTradable:      isTradable,
+			IsIndex:         isIndex,
+			LastPrice:       lastPrice,
+			NetChange:       lastPrice - closePrice,
+			OHLC: models.OHLC{
+				High:  price(8),
+				Low:   price(12),
+				Open:  price(16),
+				Close: closePrice,
+			}}
+
+		// On mode full set timestamp
+		if len(b) == modeFullIndexLength {
+			tick.Mode = string(ModeFull)
+			tick.Timestamp = models.Time{Time: time.Unix(int64(binary.BigEndian.Uint32(b[28:32])), 0)}
+		}
+
+	// Parse mode quote and mode full.
+	case modeQuoteLength, modeFullLength:
+		var (
+			lastPrice  = price(4)
+			closePrice = price(40)
+		)
+
+		// Mode quote data.
+		*tick = models.Tick{
+			Mode:               string(ModeQuote),
+			InstrumentToken:    tk,
+			IsTradable:         isTradable,
+			IsIndex:            isIndex,
+			LastPrice:          lastPrice,
+			LastTradedQuantity: binary.BigEndian.Uint32(b[8:12]),
+			AverageTradePrice:  price(12),
+			VolumeTraded:       binary.BigEndian.Uint32(b[16:20]),
+			TotalBuyQuantity:   binary.BigEndian.Uint32(b[20:24]),
+			TotalSellQuantity:  binary.BigEndian.Uint32(b[24:28]),
+			OHLC: models.OHLC{
+				Open:  price(28),
+				High:  price(32),
+				Low:   price(36),
+				Close: closePrice,
+			},
+		}
+
+		// Parse full mode.
+		if len(b) == modeFullLength {
+			tick.Mode = string(ModeFull)
+			tick.LastTradeTime = models.Time{Time: time.Unix(int64(binary.BigEndian.Uint32(b[44:48])), 0)}
+			tick.OI = binary.BigEndian.Uint32(b[48:52])
+			tick.OIDayHigh = binary.BigEndian.Uint32(b[52:56])
+			tick.OIDayLow = binary.BigEndian.Uint32(b[56:60])
+			tick.Timestamp = models.Time{Time: time.Unix(int64(binary.BigEndian.Uint32(b[60:64])), 0)}
+			tick.NetChange = lastPrice - closePrice
+
+			// Depth Information.
+			var (
+				buyPos     = 64
+				sellPos    = 124
+				depthItems = (sellPos - buyPos) / 12
+			)
+
+			for i := 0; i < depthItems; i++ {
+				tick.Depth.Buy[i] = models.DepthItem{
+					Quantity: binary.BigEndian.Uint32(b[buyPos : buyPos+4]),
+					Price:    price(buyPos + 4),
+					Orders:   uint32(binary.BigEndian.Uint16(b[buyPos+8 : buyPos+10])),
+				}
+
+				tick.Depth.Sell[i] = models.DepthItem{
+					Quantity: binary.BigEndian.Uint32(b[sellPos : sellPos+4]),
+					Price:    price(sellPos + 4),
+					Orders:   uint32(binary.BigEndian.Uint16(b[sellPos+8 : sellPos+10])),
+				}
+
+				buyPos += 12
+				sellPos += 12
+			}
+		}
+
+	default:
+		return newPacketError(tk, b, "unknown packet length %d", len(b))
+	}
+
+	return nil
+}
+
+// priceDivisor returns the divisor converting prices of stocks from paise to
+// rupees, with varying decimals based on the segment.
+func priceDivisor(seg uint32) float64 {
+	switch seg {
+	case NseCD:
+		return 10000000.0
+	case BseCD:
+		return 10000.0
+	default:
+		return 100.0
+	}
+}
@@ -0,0 +1,309 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// OverflowPolicy decides what a TickStream does with a tick its consumer has
+// no room for.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered tick to make room. It's
+	// the default.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the tick that doesn't fit.
+	OverflowDropNewest
+	// OverflowConflate keeps only the latest pending tick per instrument, so
+	// the consumer always gets the most recent state of every instrument.
+	OverflowConflate
+	// OverflowBlock waits for the consumer. No tick is lost but a slow
+	// consumer stalls the connection and every other consumer, and may get it
+	// dropped for not reading in time.
+	OverflowBlock
+)
+
+// defaultStreamBuffer is the buffer of a TickStream when none is set.
+const defaultStreamBuffer = 256
+
+// StreamOptions configures a TickStream.
+type StreamOptions struct {
+	// Buffer is the number of ticks buffered for the consumer, 256 by
+	// default. Conflating streams buffer one pending tick per instrument
+	// instead.
+	Buffer int
+	// Overflow is the policy applied when the buffer is full,
+	// OverflowDropOldest by default.
+	Overflow OverflowPolicy
+	// Mode is set for the stream's tokens when not empty.
+	Mode Mode
+}
+
+// TickStream delivers ticks on a channel independently of the OnTick callback
+// and of other streams.
+type TickStream struct {
+	// C receives the ticks. It's closed once the stream's context is done.
+	C <-chan models.Tick
+
+	ctx     context.Context
+	ch      chan models.Tick
+	tokens  map[uint32]bool
+	policy  OverflowPolicy
+	dropped atomic.Uint64
+
+	// mu serializes offers with closing the channel.
+	mu     sync.Mutex
+	closed bool
+
+	// Conflation state, the pending tick of every instrument in arrival order.
+	pending map[uint32]models.Tick
+	order   []uint32
+	notify  chan struct{}
+}
+
+// Ticks streams the ticks of tokens, or of every subscribed instrument when no
+// token is given, until ctx is done. A slow consumer loses the oldest ticks
+// rather than stalling the connection, use Stream to pick another overflow
+// policy.
+func (t *Ticker) Ticks(ctx context.Context, tokens ...uint32) <-chan models.Tick {
+	return t.Stream(ctx, StreamOptions{}, tokens...).C
+}
+
+// Stream streams the ticks of tokens, or of every subscribed instrument when
+// no token is given, until ctx is done. The tokens are subscribed now if
+// connected and on every connect otherwise; they stay subscribed once the
+// stream ends.
+func (t *Ticker) Stream(ctx context.Context, opts StreamOptions, tokens ...uint32) *TickStream {
+	stream := newTickStream(ctx, opts, tokens)
+
+	t.mu.Lock()
+	t.streams = append(t.streams[:len(t.streams):len(t.streams)], stream)
+	t.mu.Unlock()
+
+	if err := t.trackSubscription(tokens, opts.Mode); err != nil {
+		t.triggerError(err)
+	}
+
+	stream.closeOn(ctx, func() {
+		t.mu.Lock()
+		t.streams = withoutStream(t.streams, stream)
+		t.mu.Unlock()
+	})
+	return stream
+}
+
+func newTickStream(ctx context.Context, opts StreamOptions, tokens []uint32) *TickStream {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = defaultStreamBuffer
+	}
+	if opts.Overflow == OverflowConflate {
+		buffer = 0
+	}
+
+	stream := &TickStream{
+		ctx:    ctx,
+		ch:     make(chan models.Tick, buffer),
+		policy: opts.Overflow,
+	}
+	stream.C = stream.ch
+	if len(tokens) > 0 {
+		stream.tokens = make(map[uint32]bool, len(tokens))
+		for _, token := range tokens {
+			stream.tokens[token] = true
+		}
+	}
+
+	if stream.policy == OverflowConflate {
+		stream.pending = map[uint32]models.Tick{}
+		stream.notify = make(chan struct{}, 1)
+		go stream.conflate()
+	}
+	return stream
+}
+
+// closeOn closes the stream once ctx is done, after remove unregistered it.
+func (stream *TickStream) closeOn(ctx context.Context, remove func()) {
+	go func() {
+		<-ctx.Done()
+		remove()
+		stream.close()
+	}()
+}
+
+// trackSubscription records tokens as subscribed, in mode when not empty, and
+// sends the subscription right away when connected. Without a connection it's
+// sent by Resubscribe on connect.
+func (t *Ticker) trackSubscription(tokens []uint32, mode Mode) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	connected := t.writes != nil
+	if !connected {
+		for _, token := range tokens {
+			if mode != "" {
+				t.subscribedTokens[token] = mode
+			} else if _, ok := t.subscribedTokens[token]; !ok {
+				t.subscribedTokens[token] = modeEmpty
+			}
+		}
+	}
+	t.mu.Unlock()
+	if !connected {
+		return nil
+	}
+
+	err := t.Subscribe(tokens)
+	if err == nil && mode != "" {
+		err = t.SetMode(mode, tokens)
+	}
+	if errors.Is(err, ErrNotConnected) {
+		// The connection dropped meanwhile, record them for the reconnect.
+		return t.trackSubscription(tokens, mode)
+	}
+	return err
+}
+
+// untrackSubscription forgets tokens and unsubscribes them when connected.
+func (t *Ticker) untrackSubscription(tokens []uint32) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	connected := t.writes != nil
+	if !connected {
+		for _, token := range tokens {
+			delete(t.subscribedTokens, token)
+		}
+	}
+	t.mu.Unlock()
+	if !connected {
+		return nil
+	}
+
+	err := t.Unsubscribe(tokens)
+	if errors.Is(err, ErrNotConnected) {
+		// The connection dropped meanwhile, forget them for the reconnect.
+		return t.untrackSubscription(tokens)
+	}
+	return err
+}
+
+// Dropped returns the number of ticks discarded by the overflow policy.
+func (stream *TickStream) Dropped() uint64 {
+	return stream.dropped.Load()
+}
+
+// withoutStream returns a copy of streams without stream.
+func withoutStream(streams []*TickStream, stream *TickStream) []*TickStream {
+	remaining := make([]*TickStream, 0, len(streams))
+	for _, s := range streams {
+		if s != stream {
+			remaining = append(remaining, s)
+		}
+	}
+	return remaining
+}
+
+// offer hands a tick to the stream as its overflow policy dictates. It's
+// called from the read goroutine.
+func (stream *TickStream) offer(tick models.Tick) {
+	if stream.tokens != nil && !stream.tokens[tick.InstrumentToken] {
+		return
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return
+	}
+
+	switch stream.policy {
+	case OverflowBlock:
+		select {
+		case stream.ch <- tick:
+		case <-stream.ctx.Done():
+		}
+	case OverflowDropNewest:
+		select {
+		case stream.ch <- tick:
+		default:
+			stream.dropped.Add(1)
+		}
+	case OverflowConflate:
+		if _, ok := stream.pending[tick.InstrumentToken]; ok {
+			stream.dropped.Add(1)
+		} else {
+			stream.order = append(stream.order, tick.InstrumentToken)
+		}
+		stream.pending[tick.InstrumentToken] = tick
+		select {
+		case stream.notify <- struct{}{}:
+		default:
+		}
+	default:
+		for {
+			select {
+			case stream.ch <- tick:
+				return
+			default:
+			}
+			select {
+			case <-stream.ch:
+				stream.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+// conflate sends the pending ticks of a conflating stream in arrival order.
+// It's the only sender on the channel and closes it once ctx is done.
+func (stream *TickStream) conflate() {
+	defer close(stream.ch)
+
+	for {
+		select {
+		case <-stream.ctx.Done():
+			return
+		case <-stream.notify:
+		}
+
+		for {
+			stream.mu.Lock()
+			if len(stream.order) == 0 {
+				stream.mu.Unlock()
+				break
+			}
+			token := stream.order[0]
+			stream.order = stream.order[1:]
+			tick := stream.pending[token]
+			delete(stream.pending, token)
+			stream.mu.Unlock()
+
+			select {
+			case stream.ch <- tick:
+			case <-stream.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (stream *TickStream) close() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	stream.closed = true
+
+	if stream.policy != OverflowConflate {
+		close(stream.ch)
+	}
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+func offerTicks(stream *TickStream, tokens ...uint32) {
+	for i, token := range tokens {
+		stream.offer(models.Tick{InstrumentToken: token, LastPrice: float64(i)})
+	}
+}
+
+func receiveTicks(t *testing.T, stream *TickStream, n int) []models.Tick {
+	t.Helper()
+
+	ticks := make([]models.Tick, 0, n)
+	for len(ticks) < n {
+		select {
+		case tick := <-stream.C:
+			ticks = append(ticks, tick)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d ticks, want %d", len(ticks), n)
+		}
+	}
+	return ticks
+}
+
+func TestTickStreamOverflow(t *testing.T) {
+	ticker, err := KiteTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("drop oldest", func(t *testing.T) {
+		stream := ticker.Stream(ctx, StreamOptions{Buffer: 2, Overflow: OverflowDropOldest})
+		offerTicks(stream, 1, 2, 3, 4)
+
+		ticks := receiveTicks(t, stream, 2)
+		if ticks[0].LastPrice != 2 || ticks[1].LastPrice != 3 || stream.Dropped() != 2 {
+			t.Errorf("got prices %v, %v with %d dropped, want 2, 3 with 2 dropped", ticks[0].LastPrice, ticks[1].LastPrice, stream.Dropped())
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		stream := ticker.Stream(ctx, StreamOptions{Buffer: 2})
+		// Offering more than fits doesn't block.
+		offerTicks(stream, 1, 2, 3, 4)
+		if stream.Dropped() != 2 {
+			t.Errorf("got %d dropped, want 2", stream.Dropped())
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		stream := ticker.Stream(ctx, StreamOptions{Buffer: 2, Overflow: OverflowDropNewest})
+		offerTicks(stream, 1, 2, 3, 4)
+
+		ticks := receiveTicks(t, stream, 2)
+		if ticks[0].LastPrice != 0 || ticks[1].LastPrice != 1 || stream.Dropped() != 2 {
+			t.Errorf("got prices %v, %v with %d dropped, want 0, 1 with 2 dropped", ticks[0].LastPrice, ticks[1].LastPrice, stream.Dropped())
+		}
+	})
+
+	t.Run("conflate", func(t *testing.T) {
+		stream := ticker.Stream(ctx, StreamOptions{Overflow: OverflowConflate})
+		offerTicks(stream, 1, 2, 1, 2, 1)
+
+		// Every instrument ends on its latest tick, the rest are dropped.
+		var (
+			latest   = map[uint32]float64{}
+			received uint64
+		)
+		for done := false; !done; {
+			select {
+			case tick := <-stream.C:
+				latest[tick.InstrumentToken] = tick.LastPrice
+				received++
+			case <-time.After(100 * time.Millisecond):
+				done = true
+			}
+		}
+		if latest[1] != 4 || latest[2] != 3 {
+			t.Errorf("got latest prices %v, want 1: 4, 2: 3", latest)
+		}
+		if received+stream.Dropped() != 5 {
+			t.Errorf("got %d received and %d dropped, want 5 in total", received, stream.Dropped())
+		}
+	})
+
+	t.Run("filter and close", func(t *testing.T) {
+		streamCtx, streamCancel := context.WithCancel(ctx)
+		stream := ticker.Stream(streamCtx, StreamOptions{}, 7)
+		offerTicks(stream, 1, 7)
+		if tick := receiveTicks(t, stream, 1)[0]; tick.InstrumentToken != 7 {
+			t.Errorf("got token %d, want 7", tick.InstrumentToken)
+		}
+
+		streamCancel()
+		select {
+		case _, ok := <-stream.C:
+			if ok {
+				t.Error("got a tick after the stream ended")
+			}
+		case <-time.After(time.Second):
+			t.Error("stream wasn't closed")
+		}
+		if ticker.subscribedTokens[7] != modeEmpty {
+			t.Errorf("token 7 isn't recorded for subscription")
+		}
+	})
+}
+
+// TestTickStreamKeepsModes checks a stream without a mode doesn't reset the
+// mode other consumers set, whether connected or not.
+func TestTickStreamKeepsModes(t *testing.T) {
+	server, _ := newTickerServer(t, 1000)
+	ticker, err := KiteTicker(
+		WithTickerURL("ws"+strings.TrimPrefix(server.URL, "http")),
+		WithTickerAPIKey("api_key"),
+		WithTickerAccessToken("access_token"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mode := func() Mode {
+		ticker.mu.RLock()
+		defer ticker.mu.RUnlock()
+		return ticker.subscribedTokens[testInstrumentToken]
+	}
+
+	ticker.Stream(ctx, StreamOptions{Mode: ModeFull}, testInstrumentToken)
+	ticker.Stream(ctx, StreamOptions{}, testInstrumentToken)
+	if got := mode(); got != ModeFull {
+		t.Errorf("mode before connecting = %q, want %q", got, ModeFull)
+	}
+
+	connects := make(chan struct{}, 1)
+	ticker.OnConnect(func() {
+		select {
+		case connects <- struct{}{}:
+		default:
+		}
+	})
+	go ticker.ServeWithContext(ctx)
+	select {
+	case <-connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker didn't connect")
+	}
+
+	ticker.Stream(ctx, StreamOptions{}, testInstrumentToken)
+	if got := mode(); got != ModeFull {
+		t.Errorf("mode once connected = %q, want %q", got, ModeFull)
+	}
+}
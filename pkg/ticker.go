@@ -2,14 +2,19 @@ package pkg
 
 import (
 	"context"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
+	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"github.com/algotuners/zerodha-sdk-go/pkg/logging"
 	"github.com/gorilla/websocket"
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/gokiteconnect/v4/models"
@@ -18,27 +23,58 @@ import (
 // Mode represents available ticker modes.
 type Mode string
 
-// Ticker is a Kite connect ticker instance.
+// Ticker is a Kite connect ticker instance. All its methods are safe to call
+// from any goroutine.
 type Ticker struct {
-	Conn *websocket.Conn
+	// sendMu serializes sending messages along with recording their effect on
+	// the subscriptions, without holding mu while waiting on the writer.
+	sendMu sync.Mutex
 
+	// mu guards everything below, which the serve loop and its goroutines
+	// share with callers.
+	mu sync.RWMutex
+
+	backend     Backend
 	apiKey      string
+	userID      string
 	accessToken string
 	encToken    string
 
 	url                 url.URL
 	callbacks           callbacks
-	lastPingTime        time.Time
 	autoReconnect       bool
 	reconnectMaxRetries int
 	reconnectMaxDelay   time.Duration
 	connectTimeout      time.Duration
-
-	reconnectAttempt int
+	dialer              websocket.Dialer
+	header              http.Header
 
 	subscribedTokens map[uint32]Mode
+	// streams is replaced rather than modified so the read goroutine can
+	// iterate it without holding the lock.
+	streams []*TickStream
+
+	logger   *slog.Logger
+	recorder instrumentation.Recorder
 
 	cancel context.CancelFunc
+
+	// The current connection's write queue, done channel and cancel func.
+	// Writes go through a single writer goroutine as gorilla/websocket
+	// doesn't support concurrent writers.
+	writes     chan<- tickerWrite
+	connDone   <-chan struct{}
+	cancelConn context.CancelFunc
+
+	// lastPingTime is the unix nano time data was last received at.
+	lastPingTime atomic.Int64
+}
+
+// tickerWrite is a message queued for the writer goroutine.
+type tickerWrite struct {
+	messageType int
+	data        []byte
+	done        chan error
 }
 
 // callbacks represents callbacks available in ticker.
@@ -130,102 +166,202 @@ const (
 	// Interval which is used to determine if the connection is still active. If last ping time exceeds this then
 	// connection is considered as dead and reconnection is initiated.
 	dataTimeoutInterval time.Duration = 5000 * time.Millisecond
+	// Timeout for writing a message to the server.
+	writeTimeout time.Duration = 10000 * time.Millisecond
+	// Number of messages queued for the writer goroutine.
+	writeQueueSize = 64
 )
 
-var (
-	// Default ticker url.
-	tickerURL = url.URL{Scheme: "wss", Host: "ws.zerodha.com"}
-)
+// ErrNotConnected is returned when sending to the server while the ticker has
+// no connection, e.g. before Serve connected or while it reconnects.
+var ErrNotConnected = errors.New("ticker is not connected")
 
-// KiteTicker creates a new ticker instance.
-func KiteTicker(apiKey string, encToken string) *Ticker {
+// KiteTicker creates a new ticker instance connecting to the ticker of the
+// Kite Connect backend unless another backend or URL is configured. Invalid
+// options fail the construction with a descriptive error, as do credentials
+// the backend can't connect with: an enctoken without an access token on
+// BackendConnect or a missing user id on BackendWeb.
+func KiteTicker(opts ...TickerOption) (*Ticker, error) {
 	ticker := &Ticker{
-		apiKey:              apiKey,
-		encToken:            encToken,
-		url:                 tickerURL,
+		dialer:              *websocket.DefaultDialer,
 		autoReconnect:       true,
 		reconnectMaxDelay:   defaultReconnectMaxDelay,
 		reconnectMaxRetries: defaultReconnectMaxAttempts,
 		connectTimeout:      defaultConnectTimeout,
 		subscribedTokens:    map[uint32]Mode{},
 	}
-
-	return ticker
+	for _, opt := range opts {
+		if err := opt(ticker); err != nil {
+			return nil, err
+		}
+	}
+	if err := ticker.validate(); err != nil {
+		return nil, err
+	}
+	if ticker.url.Host == "" {
+		ticker.url = ticker.backend.tickerURL()
+	}
+	return ticker, nil
 }
 
 // SetRootURL sets ticker root url.
 func (t *Ticker) SetRootURL(u url.URL) {
+	t.mu.Lock()
 	t.url = u
+	t.mu.Unlock()
 }
 
 // SetAccessToken set access token.
 func (t *Ticker) SetAccessToken(aToken string) {
+	t.mu.Lock()
 	t.accessToken = aToken
+	t.mu.Unlock()
+}
+
+// SetUserID sets the user id the web backend ticker connects as.
+func (t *Ticker) SetUserID(userID string) {
+	t.mu.Lock()
+	t.userID = userID
+	t.mu.Unlock()
+}
+
+// SetEncToken set enc token.
+func (t *Ticker) SetEncToken(encToken string) {
+	t.mu.Lock()
+	t.encToken = encToken
+	t.mu.Unlock()
+}
+
+// SetLogger sets the logger for the ticker. Tokens are redacted from every
+// record regardless of the handler.
+func (t *Ticker) SetLogger(logger *slog.Logger) {
+	t.mu.Lock()
+	t.logger = logging.New(logger)
+	t.mu.Unlock()
+}
+
+func (t *Ticker) log() *slog.Logger {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.logger == nil {
+		return logging.New(nil)
+	}
+	return t.logger
+}
+
+// SetRecorder sets the recorder the ticker reports frames, ticks, parse
+// errors, reconnect attempts and tick lag to. Tick lag is measured from the
+// exchange timestamp which only full mode ticks carry, at a second's
+// resolution.
+func (t *Ticker) SetRecorder(recorder instrumentation.Recorder) {
+	t.mu.Lock()
+	t.recorder = recorder
+	t.mu.Unlock()
+}
+
+func (t *Ticker) recording() instrumentation.Recorder {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.recorder == nil {
+		return instrumentation.Nop{}
+	}
+	return t.recorder
 }
 
 // SetConnectTimeout sets default timeout for initial connect handshake
 func (t *Ticker) SetConnectTimeout(val time.Duration) {
+	t.mu.Lock()
 	t.connectTimeout = val
+	t.mu.Unlock()
 }
 
 // SetAutoReconnect enable/disable auto reconnect.
 func (t *Ticker) SetAutoReconnect(val bool) {
+	t.mu.Lock()
 	t.autoReconnect = val
+	t.mu.Unlock()
 }
 
 // SetReconnectMaxDelay sets maximum auto reconnect delay.
 func (t *Ticker) SetReconnectMaxDelay(val time.Duration) error {
-	if val > reconnectMinDelay {
+	if val < reconnectMinDelay {
 		return fmt.Errorf("ReconnectMaxDelay can't be less than %fms", reconnectMinDelay.Seconds()*1000)
 	}
 
+	t.mu.Lock()
 	t.reconnectMaxDelay = val
+	t.mu.Unlock()
 	return nil
 }
 
 // SetReconnectMaxRetries sets maximum reconnect attempts.
 func (t *Ticker) SetReconnectMaxRetries(val int) {
+	t.mu.Lock()
 	t.reconnectMaxRetries = val
+	t.mu.Unlock()
 }
 
 // OnConnect callback.
 func (t *Ticker) OnConnect(f func()) {
+	t.mu.Lock()
 	t.callbacks.onConnect = f
+	t.mu.Unlock()
 }
 
 // OnError callback.
 func (t *Ticker) OnError(f func(err error)) {
+	t.mu.Lock()
 	t.callbacks.onError = f
+	t.mu.Unlock()
 }
 
 // OnClose callback.
 func (t *Ticker) OnClose(f func(code int, reason string)) {
+	t.mu.Lock()
 	t.callbacks.onClose = f
+	t.mu.Unlock()
 }
 
 // OnMessage callback.
 func (t *Ticker) OnMessage(f func(messageType int, message []byte)) {
+	t.mu.Lock()
 	t.callbacks.onMessage = f
+	t.mu.Unlock()
 }
 
 // OnReconnect callback.
 func (t *Ticker) OnReconnect(f func(attempt int, delay time.Duration)) {
+	t.mu.Lock()
 	t.callbacks.onReconnect = f
+	t.mu.Unlock()
 }
 
 // OnNoReconnect callback.
 func (t *Ticker) OnNoReconnect(f func(attempt int)) {
+	t.mu.Lock()
 	t.callbacks.onNoReconnect = f
+	t.mu.Unlock()
 }
 
 // OnTick callback.
 func (t *Ticker) OnTick(f func(tick models.Tick)) {
+	t.mu.Lock()
 	t.callbacks.onTick = f
+	t.mu.Unlock()
 }
 
 // OnOrderUpdate callback.
 func (t *Ticker) OnOrderUpdate(f func(order kiteconnect.Order)) {
+	t.mu.Lock()
 	t.callbacks.onOrderUpdate = f
+	t.mu.Unlock()
+}
+
+// getCallbacks returns a copy of the callbacks to invoke them without the lock.
+func (t *Ticker) getCallbacks() callbacks {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.callbacks
 }
 
 // Serve starts the connection to ticker server. Since its blocking its
@@ -239,99 +375,136 @@ func (t *Ticker) Serve() {
 // routine.
 func (t *Ticker) ServeWithContext(ctx context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	t.mu.Lock()
 	t.cancel = cancel
+	t.mu.Unlock()
 
-	for {
-		select {
-		case <-ctx.Done():
+	reconnectAttempt := 0
+	for ctx.Err() == nil {
+		t.mu.RLock()
+		var (
+			autoReconnect       = t.autoReconnect
+			reconnectMaxRetries = t.reconnectMaxRetries
+			reconnectMaxDelay   = t.reconnectMaxDelay
+		)
+		t.mu.RUnlock()
+
+		// If reconnect attempt exceeds max then close the loop
+		if reconnectAttempt > reconnectMaxRetries {
+			t.log().Error("Ticker reconnect attempts exhausted", "attempt", reconnectAttempt)
+			t.triggerNoReconnect(reconnectAttempt)
 			return
-		default:
-			// If reconnect attempt exceeds max then close the loop
-			if t.reconnectAttempt > t.reconnectMaxRetries {
-				t.triggerNoReconnect(t.reconnectAttempt)
-				return
-			}
+		}
 
-			// If its a reconnect then wait exponentially based on reconnect attempt
-			if t.reconnectAttempt > 0 {
-				nextDelay := time.Duration(math.Pow(2, float64(t.reconnectAttempt))) * time.Second
-				if nextDelay > t.reconnectMaxDelay || nextDelay <= 0 {
-					nextDelay = t.reconnectMaxDelay
-				}
+		// If its a reconnect then wait exponentially based on reconnect attempt
+		if reconnectAttempt > 0 {
+			nextDelay := time.Duration(math.Pow(2, float64(reconnectAttempt))) * time.Second
+			if nextDelay > reconnectMaxDelay || nextDelay <= 0 {
+				nextDelay = reconnectMaxDelay
+			}
 
-				t.triggerReconnect(t.reconnectAttempt, nextDelay)
+			t.log().Warn("Ticker reconnecting", "attempt", reconnectAttempt, "delay", nextDelay)
+			t.recording().ObserveReconnect(reconnectAttempt)
+			t.triggerReconnect(reconnectAttempt, nextDelay)
 
-				time.Sleep(nextDelay)
+			if err := sleep(ctx, nextDelay); err != nil {
+				return
+			}
+		}
 
-				// Close the previous connection if exists
-				if t.Conn != nil {
-					t.Conn.Close()
-				}
+		conn, err := t.dial(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
+			t.log().Error("Ticker connection failed", "error", err)
+			t.triggerError(err)
 
-			// Prepare ticker URL with required params.
-			q := t.url.Query()
-			q.Set("api_key", t.apiKey)
-			q.Set("enctoken", t.encToken)
-			t.url.RawQuery = q.Encode()
-
-			// create a dialer
-			d := websocket.DefaultDialer
-			d.HandshakeTimeout = t.connectTimeout
-			conn, _, err := d.Dial(t.url.String(), nil)
-			if err != nil {
-				t.triggerError(err)
-
-				// If auto reconnect is enabled then try reconneting else return error
-				if t.autoReconnect {
-					t.reconnectAttempt++
-					continue
-				}
+			// If auto reconnect is enabled then try reconneting else return error
+			if !autoReconnect {
+				return
 			}
+			reconnectAttempt++
+			continue
+		}
 
-			// Close the connection when its done.
-			defer func() {
-				if t.Conn != nil {
-					t.Conn.Close()
-				}
-			}()
+		t.serveConn(ctx, conn)
 
-			// Assign the current connection to the instance.
-			t.Conn = conn
+		t.mu.RLock()
+		autoReconnect = t.autoReconnect
+		t.mu.RUnlock()
+		if !autoReconnect {
+			return
+		}
+		reconnectAttempt = 1
+	}
+}
 
-			// Trigger connect callback.
-			t.triggerConnect()
+// dial connects to the ticker URL with the current credentials.
+func (t *Ticker) dial(ctx context.Context) (*websocket.Conn, error) {
+	t.mu.RLock()
+	u := t.url
+	u.RawQuery = t.connectQuery().Encode()
+	d := t.dialer
+	d.HandshakeTimeout = t.connectTimeout
+	header := t.header.Clone()
+	t.mu.RUnlock()
 
-			// Resubscribe to stored tokens
-			if t.reconnectAttempt > 0 {
-				t.Resubscribe()
-			}
+	t.log().Debug("Ticker connecting", "url", u.String())
+	conn, _, err := d.DialContext(ctx, u.String(), header)
+	return conn, err
+}
 
-			// Reset auto reconnect vars
-			t.reconnectAttempt = 0
+// serveConn runs the reader, writer and connection checker of a connection
+// and returns once the connection is dropped.
+func (t *Ticker) serveConn(ctx context.Context, conn *websocket.Conn) {
+	connCtx, cancelConn := context.WithCancel(ctx)
+	defer cancelConn()
+	writes := make(chan tickerWrite, writeQueueSize)
 
-			// Set current time as last ping time
-			t.lastPingTime = time.Now()
+	t.mu.Lock()
+	t.writes = writes
+	t.connDone = connCtx.Done()
+	t.cancelConn = cancelConn
+	autoReconnect := t.autoReconnect
+	t.mu.Unlock()
 
-			// Set on close handler
-			t.Conn.SetCloseHandler(t.handleClose)
+	// Set current time as last ping time
+	t.lastPingTime.Store(time.Now().UnixNano())
 
-			var wg sync.WaitGroup
+	// Set on close handler
+	conn.SetCloseHandler(t.handleClose)
 
-			// Receive ticker data in a go routine.
-			wg.Add(1)
-			go t.readMessage(ctx, &wg)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go t.writeMessages(connCtx, conn, writes, &wg)
+	go t.readMessage(connCtx, conn, cancelConn, &wg)
 
-			// Run watcher to check last ping time and reconnect if required
-			if t.autoReconnect {
-				wg.Add(1)
-				go t.checkConnection(ctx, &wg)
-			}
+	// Run watcher to check last ping time and reconnect if required
+	if autoReconnect {
+		wg.Add(1)
+		go t.checkConnection(connCtx, cancelConn, &wg)
+	}
 
-			// Wait for go routines to finish before doing next reconnect
-			wg.Wait()
-		}
+	// Restore the stored subscriptions, those of a dropped connection and of
+	// tick streams opened before connecting.
+	t.log().Info("Ticker connected")
+	if err := t.Resubscribe(); err != nil && !errors.Is(err, ErrNotConnected) {
+		t.triggerError(err)
 	}
+
+	// Trigger connect callback.
+	t.triggerConnect()
+
+	// Wait for go routines to finish before doing next reconnect
+	wg.Wait()
+
+	t.mu.Lock()
+	t.writes = nil
+	t.connDone = nil
+	t.cancelConn = nil
+	t.mu.Unlock()
 }
 
 func (t *Ticker) handleClose(code int, reason string) error {
@@ -341,151 +514,271 @@ func (t *Ticker) handleClose(code int, reason string) error {
 
 // Trigger callback methods
 func (t *Ticker) triggerError(err error) {
-	if t.callbacks.onError != nil {
-		t.callbacks.onError(err)
+	if onError := t.getCallbacks().onError; onError != nil {
+		onError(err)
 	}
 }
 
 func (t *Ticker) triggerClose(code int, reason string) {
-	if t.callbacks.onClose != nil {
-		t.callbacks.onClose(code, reason)
+	if onClose := t.getCallbacks().onClose; onClose != nil {
+		onClose(code, reason)
 	}
 }
 
 func (t *Ticker) triggerConnect() {
-	if t.callbacks.onConnect != nil {
-		t.callbacks.onConnect()
+	if onConnect := t.getCallbacks().onConnect; onConnect != nil {
+		onConnect()
 	}
 }
 
 func (t *Ticker) triggerReconnect(attempt int, delay time.Duration) {
-	if t.callbacks.onReconnect != nil {
-		t.callbacks.onReconnect(attempt, delay)
+	if onReconnect := t.getCallbacks().onReconnect; onReconnect != nil {
+		onReconnect(attempt, delay)
 	}
 }
 
 func (t *Ticker) triggerNoReconnect(attempt int) {
-	if t.callbacks.onNoReconnect != nil {
-		t.callbacks.onNoReconnect(attempt)
+	if onNoReconnect := t.getCallbacks().onNoReconnect; onNoReconnect != nil {
+		onNoReconnect(attempt)
 	}
 }
 
 func (t *Ticker) triggerMessage(messageType int, message []byte) {
-	if t.callbacks.onMessage != nil {
-		t.callbacks.onMessage(messageType, message)
+	if onMessage := t.getCallbacks().onMessage; onMessage != nil {
+		onMessage(messageType, message)
 	}
 }
 
 func (t *Ticker) triggerTick(tick models.Tick) {
-	if t.callbacks.onTick != nil {
-		t.callbacks.onTick(tick)
+	if onTick := t.getCallbacks().onTick; onTick != nil {
+		onTick(tick)
+	}
+
+	t.mu.RLock()
+	streams := t.streams
+	t.mu.RUnlock()
+	for _, stream := range streams {
+		stream.offer(tick)
 	}
 }
 
 func (t *Ticker) triggerOrderUpdate(order kiteconnect.Order) {
-	if t.callbacks.onOrderUpdate != nil {
-		t.callbacks.onOrderUpdate(order)
+	if onOrderUpdate := t.getCallbacks().onOrderUpdate; onOrderUpdate != nil {
+		onOrderUpdate(order)
 	}
 }
 
-// Periodically check for last ping time and initiate reconnect if applicable.
-func (t *Ticker) checkConnection(ctx context.Context, wg *sync.WaitGroup) {
+// Periodically check for last ping time and drop the connection for the
+// serve loop to reconnect if no data arrived for too long.
+func (t *Ticker) checkConnection(ctx context.Context, cancelConn context.CancelFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
+
+	check := time.NewTicker(connectionCheckInterval)
+	defer check.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			// Sleep before doing next check
-			time.Sleep(connectionCheckInterval)
-
-			// If last ping time is greater then timeout interval then close the
-			// existing connection and reconnect
-			if time.Since(t.lastPingTime) > dataTimeoutInterval {
-				// Close the current connection without waiting for close frame
-				if t.Conn != nil {
-					t.Conn.Close()
-				}
-
-				// Increase reconnect attempt for next reconnection
-				t.reconnectAttempt++
-				// Mark it as done in wait group
+		case <-check.C:
+			if time.Since(time.Unix(0, t.lastPingTime.Load())) > dataTimeoutInterval {
+				t.log().Warn("Ticker connection timed out", "timeout", dataTimeoutInterval)
+				cancelConn()
 				return
 			}
 		}
 	}
 }
 
-// readMessage reads the data in a loop.
-func (t *Ticker) readMessage(ctx context.Context, wg *sync.WaitGroup) {
+// writeMessages is the only writer of a connection. It closes the connection
+// once ctx is done, which also ends readMessage.
+func (t *Ticker) writeMessages(ctx context.Context, conn *websocket.Conn, writes <-chan tickerWrite, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer conn.Close()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			mType, msg, err := t.Conn.ReadMessage()
-			if err != nil {
+		case write := <-writes:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			write.done <- conn.WriteMessage(write.messageType, write.data)
+		}
+	}
+}
+
+// readMessage reads the data in a loop.
+func (t *Ticker) readMessage(ctx context.Context, conn *websocket.Conn, cancelConn context.CancelFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer cancelConn()
+
+	var (
+		frames FrameReader
+		tick   models.Tick
+	)
+
+	for {
+		mType, msg, err := conn.ReadMessage()
+		if err != nil {
+			// Errors caused by dropping the connection ourselves aren't reported.
+			if ctx.Err() == nil {
+				t.log().Warn("Ticker read failed", "error", err)
 				t.triggerError(fmt.Errorf("Error reading data: %v", err))
-				return
 			}
+			return
+		}
 
-			// Update last ping time to check for connection
-			t.lastPingTime = time.Now()
-
-			// Trigger message.
-			t.triggerMessage(mType, msg)
-
-			// If binary message then parse and send tick.
-			if mType == websocket.BinaryMessage {
-				ticks, err := t.parseBinary(msg)
-				if err != nil {
-					t.triggerError(fmt.Errorf("Error parsing data received: %v", err))
-				}
-
-				// Trigger individual tick.
-				for _, tick := range ticks {
-					t.triggerTick(tick)
-				}
-			} else if mType == websocket.TextMessage {
-				t.processTextMessage(msg)
-			}
+		// Update last ping time to check for connection
+		t.lastPingTime.Store(time.Now().UnixNano())
+
+		// Trigger message.
+		t.triggerMessage(mType, msg)
+
+		// If binary message then parse and send tick.
+		if mType == websocket.BinaryMessage {
+			t.processBinaryMessage(&frames, &tick, msg)
+		} else if mType == websocket.TextMessage {
+			t.processTextMessage(msg)
 		}
 	}
 }
 
+// processBinaryMessage triggers the ticks of a binary frame and reports its
+// malformed packets. frames and tick are reused for every frame of the
+// connection so that decoding doesn't allocate.
+func (t *Ticker) processBinaryMessage(frames *FrameReader, tick *models.Tick, msg []byte) {
+	var (
+		recorder = t.recording()
+		now      = time.Now()
+		ticks    = 0
+	)
+
+	frames.Reset(msg)
+	for frames.Next() {
+		if err := frames.Decode(tick); err != nil {
+			t.triggerParseError(err)
+			continue
+		}
+
+		ticks++
+		if !tick.Timestamp.IsZero() {
+			recorder.ObserveTickLag(now.Sub(tick.Timestamp.Time))
+		}
+
+		// Trigger individual tick.
+		t.triggerTick(*tick)
+	}
+	if err := frames.Err(); err != nil {
+		t.triggerParseError(err)
+	}
+
+	recorder.ObserveFrame(ticks)
+}
+
+func (t *Ticker) triggerParseError(err error) {
+	t.recording().ObserveParseError()
+	t.triggerError(fmt.Errorf("Error parsing data received: %w", err))
+}
+
 // Close tries to close the connection gracefully. If the server doesn't close it
 func (t *Ticker) Close() error {
-	return t.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return t.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// Reconnect drops the current connection so that the serve loop reconnects,
+// picking up any credentials set since it was established.
+func (t *Ticker) Reconnect() {
+	t.mu.RLock()
+	cancelConn := t.cancelConn
+	t.mu.RUnlock()
+	if cancelConn != nil {
+		cancelConn()
+	}
 }
 
 // Stop the ticker instance and all the goroutines it has spawned.
 func (t *Ticker) Stop() {
-	if t.cancel != nil {
-		t.cancel()
+	t.mu.RLock()
+	cancel := t.cancel
+	t.mu.RUnlock()
+	if cancel != nil {
+		cancel()
 	}
 }
 
-// Subscribe subscribes tick for the given list of tokens.
-func (t *Ticker) Subscribe(tokens []uint32) error {
-	if len(tokens) == 0 {
-		return nil
+// write queues a message for the writer goroutine and waits until it's sent.
+func (t *Ticker) write(messageType int, data []byte) error {
+	return t.send(messageType, data, nil)
+}
+
+// send queues a message for the writer goroutine and waits until it's sent.
+// update records the message's effect on the stored subscriptions once it's
+// sent. Messages are sent one at a time so the stored subscriptions follow
+// the order messages are sent in, while mu is only held briefly so a slow
+// writer doesn't stall the read goroutine.
+func (t *Ticker) send(messageType int, data []byte, update func(subscribedTokens map[uint32]Mode)) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+
+	t.mu.RLock()
+	writes, connDone := t.writes, t.connDone
+	t.mu.RUnlock()
+	if writes == nil {
+		return ErrNotConnected
 	}
 
-	out, err := json.Marshal(tickerInput{
-		Type: "subscribe",
-		Val:  tokens,
-	})
+	write := tickerWrite{messageType: messageType, data: data, done: make(chan error, 1)}
+	select {
+	case writes <- write:
+	case <-connDone:
+		return ErrNotConnected
+	}
+
+	select {
+	case err := <-write.done:
+		if err != nil {
+			return err
+		}
+	case <-connDone:
+		return ErrNotConnected
+	}
+
+	if update != nil {
+		t.mu.Lock()
+		update(t.subscribedTokens)
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// sendInput sends a subscription message.
+func (t *Ticker) sendInput(input tickerInput, update func(subscribedTokens map[uint32]Mode)) error {
+	out, err := json.Marshal(input)
 	if err != nil {
 		return err
 	}
+	return t.send(websocket.TextMessage, out, update)
+}
 
-	// Store tokens to current subscriptions
-	for _, ts := range tokens {
-		t.subscribedTokens[ts] = modeEmpty
+// Subscribe subscribes tick for the given list of tokens. It fails with
+// ErrNotConnected without a connection; the subscriptions of a dropped
+// connection are restored on reconnect.
+func (t *Ticker) Subscribe(tokens []uint32) error {
+	if len(tokens) == 0 {
+		return nil
 	}
 
-	return t.Conn.WriteMessage(websocket.TextMessage, out)
+	return t.sendInput(tickerInput{
+		Type: "subscribe",
+		Val:  tokens,
+	}, func(subscribedTokens map[uint32]Mode) {
+		// Store new tokens to current subscriptions, keeping the mode of
+		// those already subscribed.
+		for _, ts := range tokens {
+			if _, ok := subscribedTokens[ts]; !ok {
+				subscribedTokens[ts] = modeEmpty
+			}
+		}
+	})
 }
 
 // Unsubscribe unsubscribes tick for the given list of tokens.
@@ -494,20 +787,15 @@ func (t *Ticker) Unsubscribe(tokens []uint32) error {
 		return nil
 	}
 
-	out, err := json.Marshal(tickerInput{
+	return t.sendInput(tickerInput{
 		Type: "unsubscribe",
 		Val:  tokens,
+	}, func(subscribedTokens map[uint32]Mode) {
+		// Remove tokens from current subscriptions
+		for _, ts := range tokens {
+			delete(subscribedTokens, ts)
+		}
 	})
-	if err != nil {
-		return err
-	}
-
-	// Remove tokens from current subscriptions
-	for _, ts := range tokens {
-		delete(t.subscribedTokens, ts)
-	}
-
-	return t.Conn.WriteMessage(websocket.TextMessage, out)
 }
 
 // SetMode changes mode for given list of tokens and mode.
@@ -516,23 +804,19 @@ func (t *Ticker) SetMode(mode Mode, tokens []uint32) error {
 		return nil
 	}
 
-	out, err := json.Marshal(tickerInput{
+	return t.sendInput(tickerInput{
 		Type: "mode",
 		Val:  []interface{}{mode, tokens},
+	}, func(subscribedTokens map[uint32]Mode) {
+		// Set mode in current subscriptions stored
+		for _, ts := range tokens {
+			subscribedTokens[ts] = mode
+		}
 	})
-	if err != nil {
-		return err
-	}
-
-	// Set mode in current subscriptions stored
-	for _, ts := range tokens {
-		t.subscribedTokens[ts] = mode
-	}
-
-	return t.Conn.WriteMessage(websocket.TextMessage, out)
 }
 
-// Resubscribe resubscribes to the current stored subscriptions
+// Resubscribe resubscribes to the current stored subscriptions. Replaying
+// them leaves the stored subscriptions untouched.
 func (t *Ticker) Resubscribe() error {
 	var tokens []uint32
 	modes := map[Mode][]uint32{
@@ -542,18 +826,20 @@ func (t *Ticker) Resubscribe() error {
 	}
 
 	// Make a map of mode and corresponding tokens
+	t.mu.RLock()
 	for to, mo := range t.subscribedTokens {
 		tokens = append(tokens, to)
 		if mo != modeEmpty {
 			modes[mo] = append(modes[mo], to)
 		}
 	}
+	t.mu.RUnlock()
 
-	fmt.Println("Subscribe again: ", tokens, t.subscribedTokens)
+	t.log().Debug("Ticker resubscribing", "tokens", len(tokens))
 
 	// Subscribe to tokens
 	if len(tokens) > 0 {
-		if err := t.Subscribe(tokens); err != nil {
+		if err := t.sendInput(tickerInput{Type: "subscribe", Val: tokens}, nil); err != nil {
 			return err
 		}
 	}
@@ -561,7 +847,7 @@ func (t *Ticker) Resubscribe() error {
 	// Set mode to tokens
 	for mo, tos := range modes {
 		if len(tos) > 0 {
-			if err := t.SetMode(mo, tos); err != nil {
+			if err := t.sendInput(tickerInput{Type: "mode", Val: []interface{}{mo, tos}}, nil); err != nil {
 				return err
 			}
 		}
@@ -579,7 +865,11 @@ func (t *Ticker) processTextMessage(inp []byte) {
 
 	if msg.Type == messageError {
 		// Trigger text error
-		t.triggerError(fmt.Errorf(msg.Data.(string)))
+		if text, ok := msg.Data.(string); ok {
+			t.triggerError(errors.New(text))
+		} else {
+			t.triggerError(fmt.Errorf("ticker sent an error without a message: %s", inp))
+		}
 	} else if msg.Type == messageOrder {
 		// Parse order update data
 		order := struct {
@@ -594,166 +884,3 @@ func (t *Ticker) processTextMessage(inp []byte) {
 		t.triggerOrderUpdate(order.Data)
 	}
 }
-
-// parseBinary parses the packets to ticks.
-func (t *Ticker) parseBinary(inp []byte) ([]models.Tick, error) {
-	pkts := t.splitPackets(inp)
-	var ticks []models.Tick
-
-	for _, pkt := range pkts {
-		tick, err := parsePacket(pkt)
-		if err != nil {
-			return nil, err
-		}
-
-		ticks = append(ticks, tick)
-	}
-
-	return ticks, nil
-}
-
-// splitPackets splits packet dump to individual tick packet.
-func (t *Ticker) splitPackets(inp []byte) [][]byte {
-	var pkts [][]byte
-	if len(inp) < 2 {
-		return pkts
-	}
-
-	pktLen := binary.BigEndian.Uint16(inp[0:2])
-
-	j := 2
-	for i := 0; i < int(pktLen); i++ {
-		pLen := binary.BigEndian.Uint16(inp[j : j+2])
-		pkts = append(pkts, inp[j+2:j+2+int(pLen)])
-		j = j + 2 + int(pLen)
-	}
-
-	return pkts
-}
-
-// Parse parses a tick byte array into a tick struct.
-func parsePacket(b []byte) (models.Tick, error) {
-	var (
-		tk         = binary.BigEndian.Uint32(b[0:4])
-		seg        = tk & 0xFF
-		isIndex    = seg == Indices
-		isTradable = seg != Indices
-	)
-
-	// Mode LTP parsing
-	if len(b) == modeLTPLength {
-		return models.Tick{
-			Mode:            string(ModeLTP),
-			InstrumentToken: tk,
-			IsTradable:      isTradable,
-			IsIndex:         isIndex,
-			LastPrice:       convertPrice(seg, float64(binary.BigEndian.Uint32(b[4:8]))),
-		}, nil
-	}
-
-	// Parse index mode full and mode quote data
-	if len(b) == modeQuoteIndexPacketLength || len(b) == modeFullIndexLength {
-		var (
-			lastPrice  = convertPrice(seg, float64(binary.BigEndian.Uint32(b[4:8])))
-			closePrice = convertPrice(seg, float64(binary.BigEndian.Uint32(b[20:24])))
-		)
-
-		tick := models.Tick{
-			Mode:            string(ModeQuote),
-			InstrumentToken: tk,
-			IsTradable:      isTradable,
-			IsIndex:         isIndex,
-			LastPrice:       lastPrice,
-			NetChange:       lastPrice - closePrice,
-			OHLC: models.OHLC{
-				High:  convertPrice(seg, float64(binary.BigEndian.Uint32(b[8:12]))),
-				Low:   convertPrice(seg, float64(binary.BigEndian.Uint32(b[12:16]))),
-				Open:  convertPrice(seg, float64(binary.BigEndian.Uint32(b[16:20]))),
-				Close: closePrice,
-			}}
-
-		// On mode full set timestamp
-		if len(b) == modeFullIndexLength {
-			tick.Mode = string(ModeFull)
-			tick.Timestamp = models.Time{time.Unix(int64(binary.BigEndian.Uint32(b[28:32])), 0)}
-		}
-
-		return tick, nil
-	}
-
-	// Parse mode quote.
-	var (
-		lastPrice  = convertPrice(seg, float64(binary.BigEndian.Uint32(b[4:8])))
-		closePrice = convertPrice(seg, float64(binary.BigEndian.Uint32(b[40:44])))
-	)
-
-	// Mode quote data.
-	tick := models.Tick{
-		Mode:               string(ModeQuote),
-		InstrumentToken:    tk,
-		IsTradable:         isTradable,
-		IsIndex:            isIndex,
-		LastPrice:          lastPrice,
-		LastTradedQuantity: binary.BigEndian.Uint32(b[8:12]),
-		AverageTradePrice:  convertPrice(seg, float64(binary.BigEndian.Uint32(b[12:16]))),
-		VolumeTraded:       binary.BigEndian.Uint32(b[16:20]),
-		TotalBuyQuantity:   binary.BigEndian.Uint32(b[20:24]),
-		TotalSellQuantity:  binary.BigEndian.Uint32(b[24:28]),
-		OHLC: models.OHLC{
-			Open:  convertPrice(seg, float64(binary.BigEndian.Uint32(b[28:32]))),
-			High:  convertPrice(seg, float64(binary.BigEndian.Uint32(b[32:36]))),
-			Low:   convertPrice(seg, float64(binary.BigEndian.Uint32(b[36:40]))),
-			Close: closePrice,
-		},
-	}
-
-	// Parse full mode.
-	if len(b) == modeFullLength {
-		tick.Mode = string(ModeFull)
-		tick.LastTradeTime = models.Time{time.Unix(int64(binary.BigEndian.Uint32(b[44:48])), 0)}
-		tick.OI = binary.BigEndian.Uint32(b[48:52])
-		tick.OIDayHigh = binary.BigEndian.Uint32(b[52:56])
-		tick.OIDayLow = binary.BigEndian.Uint32(b[56:60])
-		tick.Timestamp = models.Time{time.Unix(int64(binary.BigEndian.Uint32(b[60:64])), 0)}
-		tick.NetChange = lastPrice - closePrice
-
-		// Depth Information.
-		var (
-			buyPos     = 64
-			sellPos    = 124
-			depthItems = (sellPos - buyPos) / 12
-		)
-
-		for i := 0; i < depthItems; i++ {
-			tick.Depth.Buy[i] = models.DepthItem{
-				Quantity: binary.BigEndian.Uint32(b[buyPos : buyPos+4]),
-				Price:    convertPrice(seg, float64(binary.BigEndian.Uint32(b[buyPos+4:buyPos+8]))),
-				Orders:   uint32(binary.BigEndian.Uint16(b[buyPos+8 : buyPos+10])),
-			}
-
-			tick.Depth.Sell[i] = models.DepthItem{
-				Quantity: binary.BigEndian.Uint32(b[sellPos : sellPos+4]),
-				Price:    convertPrice(seg, float64(binary.BigEndian.Uint32(b[sellPos+4:sellPos+8]))),
-				Orders:   uint32(binary.BigEndian.Uint16(b[sellPos+8 : sellPos+10])),
-			}
-
-			buyPos += 12
-			sellPos += 12
-		}
-	}
-
-	return tick, nil
-}
-
-// convertPrice converts prices of stocks from paise to rupees
-// with varying decimals based on the segment.
-func convertPrice(seg uint32, val float64) float64 {
-	switch seg {
-	case NseCD:
-		return val / 10000000.0
-	case BseCD:
-		return val / 10000.0
-	default:
-		return val / 100.0
-	}
-}
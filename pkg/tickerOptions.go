@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/instrumentation"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TickerOption configures the ticker built by KiteTicker.
+type TickerOption func(*Ticker) error
+
+// WithTickerURL sets the websocket root URL of the ticker.
+func WithTickerURL(tickerURL string) TickerOption {
+	return func(t *Ticker) error {
+		if err := validateURL(tickerURL, "ws", "wss"); err != nil {
+			return fmt.Errorf("invalid ticker URL: %w", err)
+		}
+		u, _ := url.Parse(tickerURL)
+		t.SetRootURL(*u)
+		return nil
+	}
+}
+
+// WithTickerBackend selects the backend the ticker connects to. Its root URL
+// is used unless WithTickerURL is given.
+func WithTickerBackend(backend Backend) TickerOption {
+	return func(t *Ticker) error {
+		if !backend.valid() {
+			return fmt.Errorf("invalid backend %s", backend)
+		}
+		t.backend = backend
+		return nil
+	}
+}
+
+// WithTickerUserID sets the user id, required by the web backend.
+func WithTickerUserID(userID string) TickerOption {
+	return func(t *Ticker) error {
+		t.SetUserID(userID)
+		return nil
+	}
+}
+
+func WithTickerAPIKey(apiKey string) TickerOption {
+	return func(t *Ticker) error {
+		t.apiKey = apiKey
+		return nil
+	}
+}
+
+func WithTickerEncToken(encToken string) TickerOption {
+	return func(t *Ticker) error {
+		t.SetEncToken(encToken)
+		return nil
+	}
+}
+
+func WithTickerAccessToken(accessToken string) TickerOption {
+	return func(t *Ticker) error {
+		t.SetAccessToken(accessToken)
+		return nil
+	}
+}
+
+// WithTickerProxy connects through an http, https or socks5 proxy.
+func WithTickerProxy(proxyURL string) TickerOption {
+	return func(t *Ticker) error {
+		if err := validateURL(proxyURL, "http", "https", "socks5"); err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		u, _ := url.Parse(proxyURL)
+		t.dialer.Proxy = http.ProxyURL(u)
+		return nil
+	}
+}
+
+func WithTickerTLSConfig(tlsConfig *tls.Config) TickerOption {
+	return func(t *Ticker) error {
+		if tlsConfig == nil {
+			return errors.New("TLS config can't be nil")
+		}
+		t.dialer.TLSClientConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithTickerConnectTimeout sets the timeout of the websocket handshake.
+func WithTickerConnectTimeout(timeout time.Duration) TickerOption {
+	return func(t *Ticker) error {
+		if timeout <= 0 {
+			return fmt.Errorf("connect timeout must be positive, got %s", timeout)
+		}
+		t.SetConnectTimeout(timeout)
+		return nil
+	}
+}
+
+// WithTickerUserAgent sets the User-Agent sent with the websocket handshake.
+func WithTickerUserAgent(userAgent string) TickerOption {
+	return func(t *Ticker) error {
+		if userAgent == "" {
+			return errors.New("user agent can't be empty")
+		}
+		if t.header == nil {
+			t.header = http.Header{}
+		}
+		t.header.Set("User-Agent", userAgent)
+		return nil
+	}
+}
+
+// WithTickerReconnect configures auto reconnect. A maxDelay of zero keeps the
+// default.
+func WithTickerReconnect(autoReconnect bool, maxRetries int, maxDelay time.Duration) TickerOption {
+	return func(t *Ticker) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("reconnect max retries can't be negative, got %d", maxRetries)
+		}
+		if maxDelay != 0 {
+			if err := t.SetReconnectMaxDelay(maxDelay); err != nil {
+				return err
+			}
+		}
+		t.SetAutoReconnect(autoReconnect)
+		t.SetReconnectMaxRetries(maxRetries)
+		return nil
+	}
+}
+
+func WithTickerLogger(logger *slog.Logger) TickerOption {
+	return func(t *Ticker) error {
+		t.SetLogger(logger)
+		return nil
+	}
+}
+
+func WithTickerRecorder(recorder instrumentation.Recorder) TickerOption {
+	return func(t *Ticker) error {
+		t.SetRecorder(recorder)
+		return nil
+	}
+}
@@ -0,0 +1,538 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+const (
+	// MaxTokensPerConnection is the number of instruments Kite allows a
+	// ticker connection to subscribe to.
+	MaxTokensPerConnection = 3000
+	// MaxConnectionsPerAPIKey is the number of ticker connections Kite allows
+	// per API key.
+	MaxConnectionsPerAPIKey = 3
+
+	// rebalanceThreshold is the difference in tokens between the fullest and
+	// emptiest shard tolerated after unsubscribing. Moving tokens resubscribes
+	// them, so small imbalances are left alone.
+	rebalanceThreshold = 100
+	// orderUpdateWindow is the number of recent order updates remembered to
+	// drop the copies delivered on every connection.
+	orderUpdateWindow = 1024
+)
+
+// TickerPool spreads subscriptions over several Ticker connections, its
+// shards, to go beyond the instruments a single connection may subscribe to.
+// Every token is subscribed on the least loaded shard and the shards are
+// rebalanced when unsubscribing leaves them uneven. Shards connect and
+// reconnect independently and their ticks and order updates are merged, the
+// latter without the duplicates every connection receives. All its methods
+// are safe to call from any goroutine.
+type TickerPool struct {
+	shards []*Ticker
+
+	// subMu guards the token assignment and is held while sending
+	// subscriptions so they reach the shards in order.
+	subMu          sync.Mutex
+	assignment     map[uint32]int
+	modes          map[uint32]Mode
+	load           []int
+	tokensPerShard int
+
+	// mu guards the callbacks, streams and cancel func.
+	mu        sync.RWMutex
+	callbacks poolCallbacks
+	streams   []*TickStream
+	cancel    context.CancelFunc
+
+	orderUpdates recentOrderUpdates
+}
+
+// poolCallbacks are the callbacks of a TickerPool. Those of shard events get
+// the index of the shard.
+type poolCallbacks struct {
+	onTick        func(models.Tick)
+	onOrderUpdate func(kiteconnect.Order)
+	onError       func(int, error)
+	onConnect     func(int)
+	onClose       func(int, int, string)
+	onReconnect   func(int, int, time.Duration)
+	onNoReconnect func(int, int)
+}
+
+// KiteTickerPool creates a pool of connections tickers, each built with the
+// ticker options. Kite allows up to MaxConnectionsPerAPIKey connections.
+func KiteTickerPool(connections int, opts ...TickerOption) (*TickerPool, error) {
+	if connections < 1 || connections > MaxConnectionsPerAPIKey {
+		return nil, fmt.Errorf("ticker pool connections must be between 1 and %d, got %d", MaxConnectionsPerAPIKey, connections)
+	}
+
+	pool := &TickerPool{
+		assignment:     map[uint32]int{},
+		modes:          map[uint32]Mode{},
+		load:           make([]int, connections),
+		tokensPerShard: MaxTokensPerConnection,
+		orderUpdates:   newRecentOrderUpdates(orderUpdateWindow),
+	}
+	for i := 0; i < connections; i++ {
+		shard, err := KiteTicker(opts...)
+		if err != nil {
+			return nil, err
+		}
+		pool.wire(i, shard)
+		pool.shards = append(pool.shards, shard)
+	}
+	return pool, nil
+}
+
+// wire routes the events of a shard to the pool.
+func (p *TickerPool) wire(i int, shard *Ticker) {
+	shard.OnTick(p.triggerTick)
+	shard.OnOrderUpdate(p.triggerOrderUpdate)
+	shard.OnError(func(err error) {
+		if onError := p.getCallbacks().onError; onError != nil {
+			onError(i, err)
+		}
+	})
+	shard.OnConnect(func() {
+		if onConnect := p.getCallbacks().onConnect; onConnect != nil {
+			onConnect(i)
+		}
+	})
+	shard.OnClose(func(code int, reason string) {
+		if onClose := p.getCallbacks().onClose; onClose != nil {
+			onClose(i, code, reason)
+		}
+	})
+	shard.OnReconnect(func(attempt int, delay time.Duration) {
+		if onReconnect := p.getCallbacks().onReconnect; onReconnect != nil {
+			onReconnect(i, attempt, delay)
+		}
+	})
+	shard.OnNoReconnect(func(attempt int) {
+		if onNoReconnect := p.getCallbacks().onNoReconnect; onNoReconnect != nil {
+			onNoReconnect(i, attempt)
+		}
+	})
+}
+
+// Serve connects all the shards. Since its blocking its recommended to use it
+// in a go routine.
+func (p *TickerPool) Serve() {
+	p.ServeWithContext(context.Background())
+}
+
+// ServeWithContext connects all the shards and returns once all of them have
+// stopped, e.g. after exhausting their reconnect attempts or once ctx is done.
+func (p *TickerPool) ServeWithContext(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, shard := range p.shards {
+		wg.Add(1)
+		go func(shard *Ticker) {
+			defer wg.Done()
+			shard.ServeWithContext(ctx)
+		}(shard)
+	}
+	wg.Wait()
+}
+
+// Stop all the shards.
+func (p *TickerPool) Stop() {
+	p.mu.RLock()
+	cancel := p.cancel
+	p.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Reconnect drops the connection of every shard, e.g. to pick up new
+// credentials.
+func (p *TickerPool) Reconnect() {
+	for _, shard := range p.shards {
+		shard.Reconnect()
+	}
+}
+
+// SetEncToken sets the enc token of every shard.
+func (p *TickerPool) SetEncToken(encToken string) {
+	for _, shard := range p.shards {
+		shard.SetEncToken(encToken)
+	}
+}
+
+// SetAccessToken sets the access token of every shard.
+func (p *TickerPool) SetAccessToken(accessToken string) {
+	for _, shard := range p.shards {
+		shard.SetAccessToken(accessToken)
+	}
+}
+
+// Load returns the number of tokens subscribed on every shard.
+func (p *TickerPool) Load() []int {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	return append([]int(nil), p.load...)
+}
+
+// Subscribe subscribes the tokens, each on the least loaded shard. It fails
+// without subscribing any when they don't fit in the pool. Subscriptions made
+// before a shard connects are sent once it does.
+func (p *TickerPool) Subscribe(tokens []uint32) error {
+	return p.subscribe(tokens, "")
+}
+
+// SetMode changes mode for given list of tokens, subscribing those that
+// aren't yet.
+func (p *TickerPool) SetMode(mode Mode, tokens []uint32) error {
+	return p.subscribe(tokens, mode)
+}
+
+func (p *TickerPool) subscribe(tokens []uint32, mode Mode) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	unassigned := map[uint32]bool{}
+	for _, token := range tokens {
+		if _, ok := p.assignment[token]; !ok {
+			unassigned[token] = true
+		}
+	}
+	if used := p.used(); used+len(unassigned) > p.capacity() {
+		return fmt.Errorf("ticker pool is full: %d tokens subscribed, %d more don't fit in %d", used, len(unassigned), p.capacity())
+	}
+
+	var (
+		perShard = map[int][]uint32{}
+		added    = map[int][]uint32{}
+		previous = map[uint32]Mode{}
+	)
+	for _, token := range tokens {
+		shard, ok := p.assignment[token]
+		if !ok {
+			shard = p.leastLoaded()
+			p.assignment[token] = shard
+			p.load[shard]++
+			added[shard] = append(added[shard], token)
+		} else if _, seen := previous[token]; !seen && mode != "" {
+			previous[token] = p.modes[token]
+		}
+		if mode != "" || !ok {
+			p.modes[token] = mode
+		}
+		perShard[shard] = append(perShard[shard], token)
+	}
+
+	var errs []error
+	for shard, shardTokens := range perShard {
+		if err := p.shards[shard].trackSubscription(shardTokens, mode); err != nil {
+			errs = append(errs, err, p.rollback(shard, shardTokens, added[shard], previous))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback undoes the assignment of tokens to a shard that failed to
+// subscribe them, unsubscribing the added ones the shard may have taken
+// before failing. p.subMu must be held.
+func (p *TickerPool) rollback(shard int, tokens, added []uint32, previous map[uint32]Mode) error {
+	for _, token := range tokens {
+		if mode, ok := previous[token]; ok {
+			p.modes[token] = mode
+		}
+	}
+	for _, token := range added {
+		delete(p.assignment, token)
+		delete(p.modes, token)
+	}
+	p.load[shard] -= len(added)
+	return p.shards[shard].untrackSubscription(added)
+}
+
+// Unsubscribe unsubscribes the tokens and rebalances the shards if that left
+// them uneven.
+func (p *TickerPool) Unsubscribe(tokens []uint32) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	perShard := map[int][]uint32{}
+	for _, token := range tokens {
+		shard, ok := p.assignment[token]
+		if !ok {
+			continue
+		}
+		delete(p.assignment, token)
+		delete(p.modes, token)
+		p.load[shard]--
+		perShard[shard] = append(perShard[shard], token)
+	}
+
+	var errs []error
+	for shard, shardTokens := range perShard {
+		if err := p.shards[shard].untrackSubscription(shardTokens); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := p.rebalance(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// rebalance moves tokens from the fullest to the emptiest shard until they
+// differ by no more than rebalanceThreshold. p.subMu must be held.
+func (p *TickerPool) rebalance() error {
+	for {
+		fullest, emptiest := 0, 0
+		for shard, load := range p.load {
+			if load > p.load[fullest] {
+				fullest = shard
+			}
+			if load < p.load[emptiest] {
+				emptiest = shard
+			}
+		}
+		if p.load[fullest]-p.load[emptiest] <= rebalanceThreshold {
+			return nil
+		}
+
+		n := (p.load[fullest] - p.load[emptiest]) / 2
+		moved := map[Mode][]uint32{}
+		for token, shard := range p.assignment {
+			if n == 0 {
+				break
+			}
+			if shard == fullest {
+				moved[p.modes[token]] = append(moved[p.modes[token]], token)
+				n--
+			}
+		}
+
+		// The tokens are only reassigned once they moved, so a failure leaves
+		// the assignment matching the shards.
+		for mode, tokens := range moved {
+			if err := p.shards[fullest].untrackSubscription(tokens); err != nil {
+				return err
+			}
+			if err := p.shards[emptiest].trackSubscription(tokens, mode); err != nil {
+				// Put them back on the shard they're assigned to.
+				return errors.Join(err, p.shards[fullest].trackSubscription(tokens, mode))
+			}
+
+			for _, token := range tokens {
+				p.assignment[token] = emptiest
+			}
+			p.load[fullest] -= len(tokens)
+			p.load[emptiest] += len(tokens)
+		}
+	}
+}
+
+func (p *TickerPool) leastLoaded() int {
+	least := 0
+	for shard, load := range p.load {
+		if load < p.load[least] {
+			least = shard
+		}
+	}
+	return least
+}
+
+func (p *TickerPool) used() int {
+	return len(p.assignment)
+}
+
+func (p *TickerPool) capacity() int {
+	return p.tokensPerShard * len(p.shards)
+}
+
+// Ticks streams the ticks of tokens from all the shards, subscribing them, or
+// of every subscribed instrument when no token is given, until ctx is done.
+func (p *TickerPool) Ticks(ctx context.Context, tokens ...uint32) (<-chan models.Tick, error) {
+	stream, err := p.Stream(ctx, StreamOptions{}, tokens...)
+	if err != nil {
+		return nil, err
+	}
+	return stream.C, nil
+}
+
+// Stream is Ticker.Stream for the ticks of all the shards. It fails when the
+// tokens don't fit in the pool.
+func (p *TickerPool) Stream(ctx context.Context, opts StreamOptions, tokens ...uint32) (*TickStream, error) {
+	if err := p.subscribe(tokens, opts.Mode); err != nil {
+		return nil, err
+	}
+
+	stream := newTickStream(ctx, opts, tokens)
+	p.mu.Lock()
+	p.streams = append(p.streams[:len(p.streams):len(p.streams)], stream)
+	p.mu.Unlock()
+
+	stream.closeOn(ctx, func() {
+		p.mu.Lock()
+		p.streams = withoutStream(p.streams, stream)
+		p.mu.Unlock()
+	})
+	return stream, nil
+}
+
+// OnTick callback. It's called from the goroutines of all the shards, so
+// possibly concurrently.
+func (p *TickerPool) OnTick(f func(tick models.Tick)) {
+	p.mu.Lock()
+	p.callbacks.onTick = f
+	p.mu.Unlock()
+}
+
+// OnOrderUpdate callback, called once per order update however many shards
+// received it.
+func (p *TickerPool) OnOrderUpdate(f func(order kiteconnect.Order)) {
+	p.mu.Lock()
+	p.callbacks.onOrderUpdate = f
+	p.mu.Unlock()
+}
+
+// OnError callback.
+func (p *TickerPool) OnError(f func(shard int, err error)) {
+	p.mu.Lock()
+	p.callbacks.onError = f
+	p.mu.Unlock()
+}
+
+// OnConnect callback.
+func (p *TickerPool) OnConnect(f func(shard int)) {
+	p.mu.Lock()
+	p.callbacks.onConnect = f
+	p.mu.Unlock()
+}
+
+// OnClose callback.
+func (p *TickerPool) OnClose(f func(shard int, code int, reason string)) {
+	p.mu.Lock()
+	p.callbacks.onClose = f
+	p.mu.Unlock()
+}
+
+// OnReconnect callback.
+func (p *TickerPool) OnReconnect(f func(shard int, attempt int, delay time.Duration)) {
+	p.mu.Lock()
+	p.callbacks.onReconnect = f
+	p.mu.Unlock()
+}
+
+// OnNoReconnect callback.
+func (p *TickerPool) OnNoReconnect(f func(shard int, attempt int)) {
+	p.mu.Lock()
+	p.callbacks.onNoReconnect = f
+	p.mu.Unlock()
+}
+
+func (p *TickerPool) getCallbacks() poolCallbacks {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.callbacks
+}
+
+func (p *TickerPool) triggerTick(tick models.Tick) {
+	p.mu.RLock()
+	onTick, streams := p.callbacks.onTick, p.streams
+	p.mu.RUnlock()
+
+	if onTick != nil {
+		onTick(tick)
+	}
+	for _, stream := range streams {
+		stream.offer(tick)
+	}
+}
+
+func (p *TickerPool) triggerOrderUpdate(order kiteconnect.Order) {
+	if !p.orderUpdates.firstSeen(order) {
+		return
+	}
+	if onOrderUpdate := p.getCallbacks().onOrderUpdate; onOrderUpdate != nil {
+		onOrderUpdate(order)
+	}
+}
+
+// orderUpdateKey identifies an order update, the same update carrying the
+// same order state.
+type orderUpdateKey struct {
+	orderID      string
+	status       string
+	quantity     float64
+	price        float64
+	triggerPrice float64
+	filled       float64
+	pending      float64
+	cancelled    float64
+	updatedAt    int64
+}
+
+// recentOrderUpdates remembers the most recent order updates.
+type recentOrderUpdates struct {
+	mu   sync.Mutex
+	seen map[orderUpdateKey]bool
+	ring []orderUpdateKey
+	next int
+}
+
+func newRecentOrderUpdates(size int) recentOrderUpdates {
+	return recentOrderUpdates{
+		seen: make(map[orderUpdateKey]bool, size),
+		ring: make([]orderUpdateKey, 0, size),
+	}
+}
+
+// firstSeen reports whether the order update isn't among the recent ones and
+// remembers it.
+func (r *recentOrderUpdates) firstSeen(order kiteconnect.Order) bool {
+	key := orderUpdateKey{
+		orderID:      order.OrderID,
+		status:       order.Status,
+		quantity:     order.Quantity,
+		price:        order.Price,
+		triggerPrice: order.TriggerPrice,
+		filled:       order.FilledQuantity,
+		pending:      order.PendingQuantity,
+		cancelled:    order.CancelledQuantity,
+		updatedAt:    order.ExchangeUpdateTimestamp.UnixNano(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen[key] {
+		return false
+	}
+
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, key)
+	} else {
+		delete(r.seen, r.ring[r.next])
+		r.ring[r.next] = key
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.seen[key] = true
+	return true
+}
@@ -0,0 +1,448 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+func tokenRange(from, n int) []uint32 {
+	tokens := make([]uint32, n)
+	for i := range tokens {
+		tokens[i] = uint32(from + i)
+	}
+	return tokens
+}
+
+func TestTickerPoolRebalance(t *testing.T) {
+	pool, err := KiteTickerPool(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.SetMode(ModeFull, tokenRange(1, 600)); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Load(); got[0] != 200 || got[1] != 200 || got[2] != 200 {
+		t.Fatalf("Load() = %v, want 200 per shard", got)
+	}
+
+	// Empty the first shard.
+	var first []uint32
+	for token, shard := range pool.assignment {
+		if shard == 0 {
+			first = append(first, token)
+		}
+	}
+	if err := pool.Unsubscribe(first); err != nil {
+		t.Fatal(err)
+	}
+
+	load, total := pool.Load(), 0
+	least, most := load[0], load[0]
+	for i, n := range load {
+		total += n
+		least, most = min(least, n), max(most, n)
+		if subscribed := len(pool.shards[i].subscribedTokens); subscribed != n {
+			t.Errorf("shard %d has %d tokens subscribed, want %d", i, subscribed, n)
+		}
+		for token, mode := range pool.shards[i].subscribedTokens {
+			if mode != ModeFull {
+				t.Errorf("token %d moved to shard %d in mode %q, want %q", token, i, mode, ModeFull)
+			}
+		}
+	}
+	if total != 400 || most-least > rebalanceThreshold {
+		t.Errorf("Load() after unsubscribing = %v, want 400 tokens within %d", load, rebalanceThreshold)
+	}
+}
+
+func TestTickerPoolFull(t *testing.T) {
+	pool, err := KiteTickerPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.tokensPerShard = 10
+
+	if err := pool.Subscribe(tokenRange(1, 15)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Subscribe(tokenRange(11, 11)); err == nil {
+		t.Error("Subscribe() over capacity succeeded")
+	}
+	if got := pool.Load(); got[0]+got[1] != 15 {
+		t.Errorf("Load() after failing = %v, want 15 tokens", got)
+	}
+	// Already subscribed tokens don't take room.
+	if err := pool.Subscribe(tokenRange(1, 20)); err != nil {
+		t.Errorf("Subscribe() up to capacity error = %v", err)
+	}
+
+	if _, err := KiteTickerPool(MaxConnectionsPerAPIKey + 1); err == nil {
+		t.Errorf("KiteTickerPool(%d) succeeded", MaxConnectionsPerAPIKey+1)
+	}
+}
+
+func TestTickerPoolOrderUpdates(t *testing.T) {
+	pool, err := KiteTickerPool(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []kiteconnect.Order
+	pool.OnOrderUpdate(func(order kiteconnect.Order) { got = append(got, order) })
+
+	open := kiteconnect.Order{OrderID: "1", Status: "OPEN", Quantity: 10}
+	filled := open
+	filled.Status, filled.FilledQuantity = "COMPLETE", 10
+	for _, order := range []kiteconnect.Order{open, open, open, filled, filled, filled} {
+		pool.triggerOrderUpdate(order)
+	}
+	if len(got) != 2 || got[0].Status != "OPEN" || got[1].Status != "COMPLETE" {
+		t.Errorf("order updates = %+v, want OPEN then COMPLETE once", got)
+	}
+}
+
+func TestTickerPoolKeepsModes(t *testing.T) {
+	pool, err := KiteTickerPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := tokenRange(1, 10)
+	if err := pool.SetMode(ModeFull, tokens); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Subscribe(tokens); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		shard := pool.shards[pool.assignment[token]]
+		if mode := shard.subscribedTokens[token]; mode != ModeFull || pool.modes[token] != ModeFull {
+			t.Errorf("token %d mode = %q on the shard and %q in the pool, want %q", token, mode, pool.modes[token], ModeFull)
+		}
+	}
+}
+
+// poolServer is a ticker server recording the subscriptions of every
+// connection.
+type poolServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	conns []*poolConn
+}
+
+type poolConn struct {
+	writeMu sync.Mutex
+	conn    *websocket.Conn
+	// tokens maps the subscribed tokens to their mode, empty until set.
+	tokens map[uint32]Mode
+}
+
+func (c *poolConn) write(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// newPoolServer starts a ticker server applying the subscription messages of
+// every connection and sending heartbeats to keep them alive.
+func newPoolServer(t *testing.T) *poolServer {
+	t.Helper()
+
+	var (
+		server   = &poolServer{}
+		upgrader websocket.Upgrader
+	)
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		c := &poolConn{conn: conn, tokens: map[uint32]Mode{}}
+		server.mu.Lock()
+		server.conns = append(server.conns, c)
+		server.mu.Unlock()
+
+		closed := make(chan struct{})
+		defer close(closed)
+		go func() {
+			for {
+				select {
+				case <-closed:
+					return
+				case <-time.After(100 * time.Millisecond):
+					c.write(websocket.BinaryMessage, []byte{0})
+				}
+			}
+		}()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var input struct {
+				A string          `json:"a"`
+				V json.RawMessage `json:"v"`
+			}
+			if err := json.Unmarshal(data, &input); err != nil {
+				t.Errorf("ticker sent %q: %v", data, err)
+				return
+			}
+
+			var (
+				mode   Mode
+				tokens []uint32
+			)
+			if input.A == "mode" {
+				var v [2]json.RawMessage
+				err = errors.Join(json.Unmarshal(input.V, &v), json.Unmarshal(v[0], &mode), json.Unmarshal(v[1], &tokens))
+			} else {
+				err = json.Unmarshal(input.V, &tokens)
+			}
+			if err != nil {
+				t.Errorf("ticker sent %q: %v", data, err)
+				return
+			}
+
+			server.mu.Lock()
+			for _, token := range tokens {
+				switch _, ok := c.tokens[token]; {
+				case input.A == "subscribe" && !ok:
+					c.tokens[token] = modeEmpty
+				case input.A == "unsubscribe":
+					delete(c.tokens, token)
+				case input.A == "mode":
+					c.tokens[token] = mode
+				}
+			}
+			server.mu.Unlock()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// subscriptions returns the subscriptions of every connection.
+func (server *poolServer) subscriptions() []map[uint32]Mode {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	var subscriptions []map[uint32]Mode
+	for _, c := range server.conns {
+		tokens := map[uint32]Mode{}
+		for token, mode := range c.tokens {
+			tokens[token] = mode
+		}
+		subscriptions = append(subscriptions, tokens)
+	}
+	return subscriptions
+}
+
+// broadcast sends a text message on every connection.
+func (server *poolServer) broadcast(t *testing.T, message string) {
+	t.Helper()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, c := range server.conns {
+		if err := c.write(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkShards waits for the subscriptions of the server's connections to match
+// the tokens the pool assigned to each shard.
+func checkShards(t *testing.T, pool *TickerPool, server *poolServer) {
+	t.Helper()
+
+	want := func() []map[uint32]Mode {
+		pool.subMu.Lock()
+		defer pool.subMu.Unlock()
+		shards := make([]map[uint32]Mode, len(pool.shards))
+		for i := range shards {
+			shards[i] = map[uint32]Mode{}
+		}
+		for token, shard := range pool.assignment {
+			if shards[shard][token] = pool.modes[token]; pool.modes[token] == "" {
+				shards[shard][token] = modeEmpty
+			}
+		}
+		return shards
+	}()
+
+	var got []map[uint32]Mode
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		got = server.subscriptions()
+		matched := 0
+		for _, conn := range got {
+			for _, shard := range want {
+				if reflect.DeepEqual(conn, shard) {
+					matched++
+					break
+				}
+			}
+		}
+		if len(got) == len(want) && matched == len(want) {
+			return
+		}
+	}
+	t.Fatalf("connections subscribed to %s, want %s", describeShards(got), describeShards(want))
+}
+
+func describeShards(shards []map[uint32]Mode) string {
+	var counts []string
+	for _, shard := range shards {
+		counts = append(counts, fmt.Sprint(len(shard)))
+	}
+	return "[" + strings.Join(counts, " ") + "] tokens"
+}
+
+func TestTickerPoolConnected(t *testing.T) {
+	server := newPoolServer(t)
+	pool, err := KiteTickerPool(3,
+		WithTickerURL("ws"+strings.TrimPrefix(server.URL, "http")),
+		WithTickerAPIKey("api_key"),
+		WithTickerAccessToken("access_token"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		connectedMu sync.Mutex
+		connected   = map[int]bool{}
+		allUp       = make(chan struct{})
+	)
+	pool.OnConnect(func(shard int) {
+		connectedMu.Lock()
+		defer connectedMu.Unlock()
+		if connected[shard] = true; len(connected) == len(pool.shards) {
+			close(allUp)
+		}
+	})
+	var (
+		ordersMu sync.Mutex
+		orders   []kiteconnect.Order
+	)
+	pool.OnOrderUpdate(func(order kiteconnect.Order) {
+		ordersMu.Lock()
+		orders = append(orders, order)
+		ordersMu.Unlock()
+	})
+	go pool.Serve()
+	defer pool.Stop()
+
+	select {
+	case <-allUp:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shards didn't connect")
+	}
+
+	if err := pool.SetMode(ModeFull, tokenRange(1, 600)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Subscribe(tokenRange(601, 30)); err != nil {
+		t.Fatal(err)
+	}
+	checkShards(t, pool, server)
+	if got := pool.Load(); got[0] != 210 || got[1] != 210 || got[2] != 210 {
+		t.Fatalf("Load() = %v, want 210 per shard", got)
+	}
+
+	// Empty the first shard, the others then hand it some of their tokens.
+	var first []uint32
+	pool.subMu.Lock()
+	for token, shard := range pool.assignment {
+		if shard == 0 {
+			first = append(first, token)
+		}
+	}
+	pool.subMu.Unlock()
+	if err := pool.Unsubscribe(first); err != nil {
+		t.Fatal(err)
+	}
+	checkShards(t, pool, server)
+	if load := pool.Load(); load[0] == 0 || load[0]+load[1]+load[2] != 420 {
+		t.Errorf("Load() after unsubscribing = %v, want 420 tokens rebalanced", load)
+	}
+
+	// Every connection receives the order updates, they're delivered once.
+	for _, status := range []string{"OPEN", "OPEN", "COMPLETE"} {
+		server.broadcast(t, fmt.Sprintf(`{"type":"order","data":{"order_id":"1","status":%q,"quantity":10}}`, status))
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		ordersMu.Lock()
+		n := len(orders)
+		ordersMu.Unlock()
+		if n >= 2 {
+			break
+		}
+	}
+	// Give duplicates a chance to come through.
+	time.Sleep(50 * time.Millisecond)
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+	if len(orders) != 2 || orders[0].Status != "OPEN" || orders[1].Status != "COMPLETE" {
+		t.Errorf("order updates = %+v, want OPEN then COMPLETE once", orders)
+	}
+}
+
+// TestTickerPoolSubscribeFailure checks a shard failing to subscribe leaves
+// the pool's assignment and load as they were.
+func TestTickerPoolSubscribeFailure(t *testing.T) {
+	pool, err := KiteTickerPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.SetMode(ModeLTP, tokenRange(1, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Connect the shards to writers failing every write.
+	failed := errors.New("write failed")
+	for _, shard := range pool.shards {
+		writes := make(chan tickerWrite)
+		shard.writes, shard.connDone = writes, make(chan struct{})
+		go func() {
+			for write := range writes {
+				write.done <- failed
+			}
+		}()
+		defer close(writes)
+	}
+
+	if err := pool.SetMode(ModeFull, tokenRange(1, 8)); !errors.Is(err, failed) {
+		t.Fatalf("SetMode() error = %v, want %v", err, failed)
+	}
+	if got := pool.Load(); got[0] != 2 || got[1] != 2 {
+		t.Errorf("Load() = %v, want the 2 tokens per shard from before", got)
+	}
+	if len(pool.assignment) != 4 {
+		t.Errorf("%d tokens assigned, want 4", len(pool.assignment))
+	}
+	for token, mode := range pool.modes {
+		if mode != ModeLTP {
+			t.Errorf("token %d mode = %q, want %q", token, mode, ModeLTP)
+		}
+	}
+	for i, shard := range pool.shards {
+		if len(shard.subscribedTokens) != 2 {
+			t.Errorf("shard %d has %d tokens subscribed, want 2", i, len(shard.subscribedTokens))
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// testInstrumentToken is an NSE cash instrument token, its segment being the
+// lowest byte.
+const testInstrumentToken uint32 = 408065
+
+// ltpFrame returns a binary frame carrying a single LTP packet.
+func ltpFrame(token uint32, price int32) []byte {
+	frame := make([]byte, 4+modeLTPLength)
+	binary.BigEndian.PutUint16(frame[0:2], 1)
+	binary.BigEndian.PutUint16(frame[2:4], modeLTPLength)
+	binary.BigEndian.PutUint32(frame[4:8], token)
+	binary.BigEndian.PutUint32(frame[8:12], uint32(price))
+	return frame
+}
+
+// newTickerServer starts a websocket server streaming LTP frames to every
+// connection and dropping each connection after dropAfter frames.
+func newTickerServer(t *testing.T, dropAfter int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var (
+		upgrader websocket.Upgrader
+		received atomic.Int64
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+				received.Add(1)
+			}
+		}()
+
+		for i := 0; i < dropAfter; i++ {
+			select {
+			case <-closed:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, ltpFrame(testInstrumentToken, int32(i))); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func newTestTicker(t *testing.T, server *httptest.Server) *Ticker {
+	t.Helper()
+
+	ticker, err := KiteTicker(
+		WithTickerURL("ws"+strings.TrimPrefix(server.URL, "http")),
+		WithTickerAPIKey("api_key"),
+		WithTickerAccessToken("access_token"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reconnect quickly, the setter doesn't allow less than reconnectMinDelay.
+	ticker.reconnectMaxDelay = 10 * time.Millisecond
+	return ticker
+}
+
+func TestTickerNotConnected(t *testing.T) {
+	ticker, err := KiteTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ticker.Subscribe([]uint32{testInstrumentToken}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Subscribe() error = %v, want ErrNotConnected", err)
+	}
+	if err := ticker.SetMode(ModeFull, []uint32{testInstrumentToken}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("SetMode() error = %v, want ErrNotConnected", err)
+	}
+	if err := ticker.Close(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Close() error = %v, want ErrNotConnected", err)
+	}
+	ticker.Reconnect()
+	ticker.Stop()
+}
+
+func TestKiteTickerCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []TickerOption
+		want    string
+		wantErr bool
+	}{
+		{"connect", []TickerOption{WithTickerAPIKey("key"), WithTickerAccessToken("access")}, "access_token=access&api_key=key", false},
+		{"connect set later", nil, "access_token=&api_key=", false},
+		{"connect enctoken only", []TickerOption{WithTickerAPIKey("key"), WithTickerEncToken("enc")}, "", true},
+		{"connect without API key", []TickerOption{WithTickerAccessToken("access")}, "", true},
+		{"web", []TickerOption{WithTickerBackend(BackendWeb), WithTickerUserID("AB1234"), WithTickerEncToken("enc")}, "api_key=&enctoken=enc&user_id=AB1234", false},
+		{"web without user id", []TickerOption{WithTickerBackend(BackendWeb), WithTickerEncToken("enc")}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticker, err := KiteTicker(tt.opts...)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("KiteTicker() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := ticker.connectQuery().Encode(); got != tt.want {
+				t.Errorf("connect query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTickerConcurrentUse hammers every public method from several goroutines
+// while the server streams ticks and drops connections. Run it with -race.
+func TestTickerConcurrentUse(t *testing.T) {
+	server, received := newTickerServer(t, 200)
+	ticker := newTestTicker(t, server)
+
+	var (
+		ticks    atomic.Int64
+		onTick   = func(models.Tick) { ticks.Add(1) }
+		served   = make(chan struct{})
+		connects = make(chan struct{}, 1)
+	)
+	ticker.OnTick(onTick)
+	ticker.OnError(func(error) {})
+	ticker.OnConnect(func() {
+		select {
+		case connects <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		defer close(served)
+		ticker.Serve()
+	}()
+
+	select {
+	case <-connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker didn't connect")
+	}
+
+	check := func(name string, err error) {
+		if err != nil && !errors.Is(err, ErrNotConnected) {
+			t.Errorf("%s() error = %v", name, err)
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		stop = time.Now().Add(time.Second)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; time.Now().Before(stop); j++ {
+				tokens := []uint32{uint32(i*100000 + j), testInstrumentToken}
+				check("Subscribe", ticker.Subscribe(tokens))
+				check("SetMode", ticker.SetMode(ModeFull, tokens))
+				check("Unsubscribe", ticker.Unsubscribe(tokens[:1]))
+
+				switch j % 100 {
+				case 10:
+					check("Resubscribe", ticker.Resubscribe())
+				case 20:
+					ticker.SetEncToken("enctoken")
+					ticker.SetAccessToken("access_token")
+					ticker.OnTick(onTick)
+				}
+				time.Sleep(100 * time.Microsecond)
+			}
+		}(i)
+	}
+
+	// Drop the connection from the outside as well as the server does.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for time.Now().Before(stop) {
+			time.Sleep(150 * time.Millisecond)
+			ticker.Reconnect()
+		}
+	}()
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ticks.Load() == 0 || received.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d ticks and the server %d messages, want both", ticks.Load(), received.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ticker.Stop()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after Stop")
+	}
+
+	if err := ticker.Subscribe([]uint32{testInstrumentToken}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Subscribe() after Stop error = %v, want ErrNotConnected", err)
+	}
+}
+
+// TestTickerSendDoesNotHoldLock checks a message stuck behind a slow writer
+// neither blocks the lock the read goroutine needs nor records its effect.
+func TestTickerSendDoesNotHoldLock(t *testing.T) {
+	ticker, err := KiteTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connDone := make(chan struct{})
+	ticker.writes, ticker.connDone = make(chan tickerWrite), connDone
+
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- ticker.Subscribe([]uint32{testInstrumentToken})
+	}()
+
+	locked := make(chan struct{})
+	go func() {
+		ticker.getCallbacks()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("getCallbacks blocked behind a pending write")
+	}
+
+	close(connDone)
+	if err := <-subscribed; !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Subscribe() error = %v, want ErrNotConnected", err)
+	}
+	if _, ok := ticker.subscribedTokens[testInstrumentToken]; ok {
+		t.Error("failed Subscribe() recorded the token")
+	}
+}
+
+// TestTickerKeepsModes checks subscribing again and reconnecting keep the
+// mode of subscribed tokens.
+func TestTickerKeepsModes(t *testing.T) {
+	server, _ := newTickerServer(t, 1000)
+	ticker := newTestTicker(t, server)
+
+	connects := make(chan struct{}, 1)
+	ticker.OnConnect(func() {
+		select {
+		case connects <- struct{}{}:
+		default:
+		}
+	})
+	go ticker.Serve()
+	defer ticker.Stop()
+
+	tokens := []uint32{testInstrumentToken}
+	mode := func() Mode {
+		ticker.mu.RLock()
+		defer ticker.mu.RUnlock()
+		return ticker.subscribedTokens[testInstrumentToken]
+	}
+
+	<-connects
+	if err := ticker.Subscribe(tokens); err != nil {
+		t.Fatal(err)
+	}
+	if err := ticker.SetMode(ModeFull, tokens); err != nil {
+		t.Fatal(err)
+	}
+	if err := ticker.Subscribe(tokens); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(); got != ModeFull {
+		t.Errorf("mode after subscribing again = %q, want %q", got, ModeFull)
+	}
+
+	ticker.Reconnect()
+	select {
+	case <-connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ticker didn't reconnect")
+	}
+	if got := mode(); got != ModeFull {
+		t.Errorf("mode after reconnecting = %q, want %q", got, ModeFull)
+	}
+}
+
+func TestTickerErrorMessages(t *testing.T) {
+	ticker, err := KiteTicker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []error
+	ticker.OnError(func(err error) { got = append(got, err) })
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`{"type":"error","data":"Invalid mode 100% full"}`, "Invalid mode 100% full"},
+		{`{"type":"error","data":{"code":42}}`, `ticker sent an error without a message: {"type":"error","data":{"code":42}}`},
+		{`{"type":"error"}`, `ticker sent an error without a message: {"type":"error"}`},
+	}
+
+	for _, tt := range tests {
+		got = nil
+		ticker.processTextMessage([]byte(tt.message))
+		if len(got) != 1 || got[0].Error() != tt.want {
+			t.Errorf("processTextMessage(%s) triggered %v, want %q", tt.message, got, tt.want)
+		}
+	}
+}
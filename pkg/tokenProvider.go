@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"os"
+	"strings"
+)
+
+// Credentials are the tokens handed out by a TokenProvider. Empty fields leave
+// the corresponding token on the client untouched.
+type Credentials struct {
+	EncToken    string `json:"enctoken"`
+	AccessToken string `json:"access_token"`
+}
+
+// TokenProvider obtains fresh credentials once the current ones have been
+// rejected with a TokenException.
+type TokenProvider interface {
+	Token(ctx context.Context) (Credentials, error)
+}
+
+// TokenProviderFunc adapts a callback to a TokenProvider.
+type TokenProviderFunc func(ctx context.Context) (Credentials, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// FileTokenProvider re-reads credentials from a JSON file holding `enctoken`
+// and/or `access_token`, e.g. one rewritten by an external login job.
+func FileTokenProvider(path string) TokenProvider {
+	return TokenProviderFunc(func(ctx context.Context) (Credentials, error) {
+		var credentials Credentials
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return credentials, err
+		}
+
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return credentials, fmt.Errorf("error parsing token file %s: %w", path, err)
+		}
+		return credentials, nil
+	})
+}
+
+// RenewTokenProvider renews the access token with a Kite Connect refresh token.
+func (kiteHttpClient *KiteHttpClient) RenewTokenProvider(refreshToken string, apiSecret string) TokenProvider {
+	return TokenProviderFunc(func(ctx context.Context) (Credentials, error) {
+		tokens, err := kiteHttpClient.RenewAccessTokenWithContext(ctx, refreshToken, apiSecret)
+		return Credentials{AccessToken: tokens.AccessToken}, err
+	})
+}
+
+// SetTokenProvider sets the provider used to refresh credentials when a
+// request fails with a TokenException.
+func (kiteHttpClient *KiteHttpClient) SetTokenProvider(tokenProvider TokenProvider) {
+	kiteHttpClient.tokenProvider = tokenProvider
+}
+
+// OnTokenRefresh registers a callback invoked with the new credentials after
+// every successful refresh.
+func (kiteHttpClient *KiteHttpClient) OnTokenRefresh(f func(credentials Credentials)) {
+	kiteHttpClient.onTokenRefresh = append(kiteHttpClient.onTokenRefresh, f)
+}
+
+// AttachTicker hands refreshed credentials to the ticker and makes it
+// reconnect with them.
+func (kiteHttpClient *KiteHttpClient) AttachTicker(ticker *Ticker) {
+	kiteHttpClient.OnTokenRefresh(func(credentials Credentials) {
+		if credentials.EncToken != "" {
+			ticker.SetEncToken(credentials.EncToken)
+		}
+		if credentials.AccessToken != "" {
+			ticker.SetAccessToken(credentials.AccessToken)
+		}
+		ticker.Reconnect()
+	})
+}
+
+func (kiteHttpClient *KiteHttpClient) shouldRefresh(uri string, err error) bool {
+	if kiteHttpClient.tokenProvider == nil || err == nil {
+		return false
+	}
+
+	// Session endpoints exchange tokens themselves, refreshing on them would recurse.
+	if strings.HasPrefix(uri, "/session/") {
+		return false
+	}
+
+	return errors.Is(err, httpUtils.ErrTokenExpired)
+}
+
+// refreshCredentials swaps in fresh credentials from the TokenProvider.
+// Concurrent callers that failed with the same stale Authorization share a
+// single refresh.
+func (kiteHttpClient *KiteHttpClient) refreshCredentials(ctx context.Context, staleAuth string) error {
+	kiteHttpClient.refreshMu.Lock()
+	defer kiteHttpClient.refreshMu.Unlock()
+
+	// Another request refreshed the credentials while this one waited.
+	if kiteHttpClient.authorization() != staleAuth {
+		return nil
+	}
+
+	credentials, err := kiteHttpClient.tokenProvider.Token(ctx)
+	if err != nil {
+		kiteHttpClient.log().Error("Refreshing credentials failed", "error", err)
+		return err
+	}
+	if credentials.EncToken == "" && credentials.AccessToken == "" {
+		return httpUtils.NewErrorHelper(httpUtils.TokenError, "Token provider returned no credentials", nil)
+	}
+
+	kiteHttpClient.credentialsMu.Lock()
+	if credentials.EncToken != "" {
+		kiteHttpClient.encToken = credentials.EncToken
+	}
+	if credentials.AccessToken != "" {
+		kiteHttpClient.accessToken = credentials.AccessToken
+	}
+	kiteHttpClient.credentialsMu.Unlock()
+	kiteHttpClient.log().Info("Refreshed credentials after TokenException")
+
+	for _, f := range kiteHttpClient.onTokenRefresh {
+		f(credentials)
+	}
+	return nil
+}
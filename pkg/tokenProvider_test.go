@@ -0,0 +1,246 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/gorilla/websocket"
+)
+
+// newRefreshServer starts a stand-in for the Kite API accepting only the
+// Authorization accept. It returns the method, path and Authorization of every
+// request received.
+func newRefreshServer(t *testing.T, accept string) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+auth)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if auth != accept {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"status":"error","error_type":"TokenException","message":"Invalid session"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"user_id":%q}}`, testUserID)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+// countingProvider returns a TokenProvider handing out credentials and the
+// number of times it was called.
+func countingProvider(credentials Credentials) (TokenProvider, *atomic.Int64) {
+	var calls atomic.Int64
+	return TokenProviderFunc(func(ctx context.Context) (Credentials, error) {
+		calls.Add(1)
+		// Keep the refresh in flight long enough for concurrent requests to
+		// pile up behind it.
+		time.Sleep(10 * time.Millisecond)
+		return credentials, nil
+	}), &calls
+}
+
+func newRefreshClient(t *testing.T, server *httptest.Server, tokenProvider TokenProvider, opts ...Option) *KiteHttpClient {
+	t.Helper()
+
+	opts = append([]Option{WithEncToken("stale"), WithBaseURL(server.URL), WithRateLimiter(nil)}, opts...)
+	client, err := KiteConnect(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetTokenProvider(tokenProvider)
+	return client
+}
+
+func TestTokenRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replays a GET once", func(t *testing.T) {
+		server, requests := newRefreshServer(t, "enctoken fresh")
+		tokenProvider, calls := countingProvider(Credentials{EncToken: "fresh"})
+		client := newRefreshClient(t, server, tokenProvider)
+
+		var refreshed []Credentials
+		client.OnTokenRefresh(func(credentials Credentials) { refreshed = append(refreshed, credentials) })
+
+		if profile, err := client.GetUserProfile(); err != nil || profile.UserID != testUserID {
+			t.Fatalf("GetUserProfile() = %+v, %v", profile, err)
+		}
+		want := []string{"GET /user/profile enctoken stale", "GET /user/profile enctoken fresh"}
+		if got := requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("requests = %q, want %q", got, want)
+		}
+		if calls.Load() != 1 || len(refreshed) != 1 || refreshed[0].EncToken != "fresh" {
+			t.Errorf("provider called %d times, OnTokenRefresh with %+v, want once with the fresh enctoken", calls.Load(), refreshed)
+		}
+	})
+
+	t.Run("doesn't replay a POST", func(t *testing.T) {
+		server, requests := newRefreshServer(t, "enctoken fresh")
+		tokenProvider, calls := countingProvider(Credentials{EncToken: "fresh"})
+		client := newRefreshClient(t, server, tokenProvider)
+
+		err := client.doEnvelope(ctx, http.MethodPost, "/orders/regular", nil, nil, nil)
+		if !errors.Is(err, httpUtils.ErrTokenExpired) {
+			t.Errorf("POST error = %v, want %v", err, httpUtils.ErrTokenExpired)
+		}
+		if got := requests(); len(got) != 1 {
+			t.Errorf("requests = %q, want the POST sent once", got)
+		}
+		// The next requests use the refreshed credentials.
+		if calls.Load() != 1 || client.GetEncToken() != "fresh" {
+			t.Errorf("provider called %d times, enctoken %q, want once and fresh", calls.Load(), client.GetEncToken())
+		}
+	})
+
+	t.Run("skips session endpoints", func(t *testing.T) {
+		server, requests := newRefreshServer(t, "enctoken fresh")
+		tokenProvider, calls := countingProvider(Credentials{EncToken: "fresh"})
+		client := newRefreshClient(t, server, tokenProvider)
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			if err := client.doEnvelope(ctx, method, "/session/token", nil, nil, nil); !errors.Is(err, httpUtils.ErrTokenExpired) {
+				t.Errorf("%s /session/token error = %v, want %v", method, err, httpUtils.ErrTokenExpired)
+			}
+		}
+		if got := requests(); calls.Load() != 0 || len(got) != 2 {
+			t.Errorf("provider called %d times for requests %q, want no refresh", calls.Load(), got)
+		}
+	})
+
+	t.Run("refreshes once for concurrent requests", func(t *testing.T) {
+		server, _ := newRefreshServer(t, "enctoken fresh")
+		tokenProvider, calls := countingProvider(Credentials{EncToken: "fresh"})
+		client := newRefreshClient(t, server, tokenProvider)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := client.GetUserProfile(); err != nil {
+					t.Errorf("GetUserProfile() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if calls.Load() != 1 {
+			t.Errorf("provider called %d times, want once", calls.Load())
+		}
+	})
+}
+
+func TestFileTokenProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	write := func(encToken string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(fmt.Sprintf(`{"enctoken":%q}`, encToken)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokenProvider := FileTokenProvider(path)
+	for _, encToken := range []string{"first", "second"} {
+		write(encToken)
+		credentials, err := tokenProvider.Token(context.Background())
+		if err != nil || credentials.EncToken != encToken {
+			t.Errorf("Token() = %+v, %v, want enctoken %q", credentials, err, encToken)
+		}
+	}
+
+	// The client picks up whatever the file holds when the session expires.
+	server, _ := newRefreshServer(t, "enctoken fresh")
+	client := newRefreshClient(t, server, tokenProvider)
+	write("fresh")
+	if _, err := client.GetUserProfile(); err != nil {
+		t.Errorf("GetUserProfile() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokenProvider.Token(context.Background()); err == nil {
+		t.Error("Token() of a malformed file succeeded")
+	}
+}
+
+func TestAttachTicker(t *testing.T) {
+	var upgrader websocket.Upgrader
+	accessTokens := make(chan string, 4)
+	tickerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		accessTokens <- r.URL.Query().Get("access_token")
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(tickerServer.Close)
+
+	ticker, err := KiteTicker(
+		WithTickerURL("ws"+strings.TrimPrefix(tickerServer.URL, "http")),
+		WithTickerAPIKey("key"),
+		WithTickerAccessToken("stale"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticker.reconnectMaxDelay = 10 * time.Millisecond
+	go ticker.Serve()
+	defer ticker.Stop()
+
+	next := func() string {
+		t.Helper()
+		select {
+		case accessToken := <-accessTokens:
+			return accessToken
+		case <-time.After(5 * time.Second):
+			t.Fatal("ticker didn't connect")
+			return ""
+		}
+	}
+	if got := next(); got != "stale" {
+		t.Fatalf("ticker connected with access token %q, want stale", got)
+	}
+
+	server, _ := newRefreshServer(t, "token key:fresh")
+	tokenProvider, _ := countingProvider(Credentials{AccessToken: "fresh"})
+	client := newRefreshClient(t, server, tokenProvider, WithAPIKey("key"), WithAccessToken("stale"))
+	client.AttachTicker(ticker)
+
+	if _, err := client.GetUserProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "fresh" {
+		t.Errorf("ticker reconnected with access token %q, want fresh", got)
+	}
+}
@@ -0,0 +1,68 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 as
+// used by the Kite two-factor login: HMAC-SHA1, 6 digits and a 30 second step.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a generated code.
+	Digits = 6
+	// Period is the time step a code is valid for.
+	Period = 30 * time.Second
+)
+
+var digitsPower = [...]uint32{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// DecodeSecret decodes a base32 secret as shown alongside the QR code when
+// enabling TOTP. Case, spaces and padding are ignored.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("invalid TOTP secret: empty")
+	}
+	return key, nil
+}
+
+// Generate returns the code of a base32 secret at t.
+func Generate(secret string, t time.Time) (string, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return Code(key, t), nil
+}
+
+// Code returns the code of key at t.
+func Code(key []byte, t time.Time) string {
+	return HOTP(key, uint64(t.Unix()/int64(Period/time.Second)), Digits)
+}
+
+// HOTP returns the RFC 4226 one-time password of key for counter, digits
+// being at most 8.
+func HOTP(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%digitsPower[digits])
+}
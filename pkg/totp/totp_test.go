@@ -0,0 +1,69 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcKey is the SHA1 key of the RFC 4226 and RFC 6238 test vectors.
+var rfcKey = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := HOTP(rfcKey, uint64(counter), 6); got != code {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, SHA1.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		if got := HOTP(rfcKey, uint64(tt.unix/30), 8); got != tt.code {
+			t.Errorf("8 digit code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+		// Kite uses 6 digits, the low digits of the same value.
+		if got := Code(rfcKey, at); got != tt.code[2:] {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.code[2:])
+		}
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	for _, secret := range []string{
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ====",
+	} {
+		key, err := DecodeSecret(secret)
+		if err != nil || string(key) != string(rfcKey) {
+			t.Errorf("DecodeSecret(%q) = %q, %v, want %q", secret, key, err, rfcKey)
+		}
+	}
+
+	for _, secret := range []string{"", "not base32!"} {
+		if _, err := DecodeSecret(secret); err == nil {
+			t.Errorf("DecodeSecret(%q) succeeded", secret)
+		}
+	}
+
+	code, err := Generate("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Errorf("Generate() = %s, %v, want 287082", code, err)
+	}
+}
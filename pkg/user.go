@@ -1,9 +1,12 @@
 package pkg
 
 import (
+	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
 	"github.com/algotuners/zerodha-sdk-go/pkg/models"
 	"net/http"
 	"net/url"
@@ -12,7 +15,9 @@ import (
 // UserSession represents the response after a successful authentication.
 type UserSession struct {
 	UserProfile
-	UserSessionTokens
+	// UserSessionTokens is read from the same object by UnmarshalJSON. It's
+	// skipped by the field tags as its user_id clashes with UserProfile's.
+	UserSessionTokens `json:"-"`
 
 	UserID      string      `json:"user_id"`
 	APIKey      string      `json:"api_key"`
@@ -20,6 +25,24 @@ type UserSession struct {
 	LoginTime   models.Time `json:"login_time"`
 }
 
+// userSessionFields has the fields of UserSession without its JSON methods.
+type userSessionFields UserSession
+
+func (session *UserSession) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*userSessionFields)(session)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &session.UserSessionTokens)
+}
+
+func (session UserSession) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		userSessionFields
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}{userSessionFields(session), session.AccessToken, session.RefreshToken})
+}
+
 type UserSessionTokens struct {
 	UserID       string `json:"user_id"`
 	AccessToken  string `json:"access_token"`
@@ -44,24 +67,163 @@ type UserProfile struct {
 	Exchanges     []string `json:"exchanges"`
 }
 
+// AvailableMargins represents the available margins from the margins response for a single segment.
+type AvailableMargins struct {
+	AdHocMargin    float64 `json:"adhoc_margin"`
+	Cash           float64 `json:"cash"`
+	Collateral     float64 `json:"collateral"`
+	IntradayPayin  float64 `json:"intraday_payin"`
+	LiveBalance    float64 `json:"live_balance"`
+	OpeningBalance float64 `json:"opening_balance"`
+}
+
+// UsedMargins represents the used margins from the margins response for a single segment.
+type UsedMargins struct {
+	Debits           float64 `json:"debits"`
+	Exposure         float64 `json:"exposure"`
+	M2MRealised      float64 `json:"m2m_realised"`
+	M2MUnrealised    float64 `json:"m2m_unrealised"`
+	OptionPremium    float64 `json:"option_premium"`
+	Payout           float64 `json:"payout"`
+	Span             float64 `json:"span"`
+	HoldingSales     float64 `json:"holding_sales"`
+	Turnover         float64 `json:"turnover"`
+	LiquidCollateral float64 `json:"liquid_collateral"`
+	StockCollateral  float64 `json:"stock_collateral"`
+	Delivery         float64 `json:"delivery"`
+}
+
+// Margins represents the user margins for a segment.
+type Margins struct {
+	Category  string           `json:"-"`
+	Enabled   bool             `json:"enabled"`
+	Net       float64          `json:"net"`
+	Available AvailableMargins `json:"available"`
+	Used      UsedMargins      `json:"utilised"`
+}
+
+// AllMargins contains both equity and commodity margins.
+type AllMargins struct {
+	Equity    Margins `json:"equity"`
+	Commodity Margins `json:"commodity"`
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserProfile() (UserProfile, error) {
+	return kiteHttpClient.GetUserProfileWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserProfileWithContext(ctx context.Context) (UserProfile, error) {
+	var userProfile UserProfile
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIUserProfile, nil, nil, &userProfile)
+	return userProfile, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserMargins() (AllMargins, error) {
+	return kiteHttpClient.GetUserMarginsWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserMarginsWithContext(ctx context.Context) (AllMargins, error) {
+	var allMargins AllMargins
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, constants.URIUserMargins, nil, nil, &allMargins)
+	allMargins.Equity.Category = constants.MarginsEquity
+	allMargins.Commodity.Category = constants.MarginsCommodity
+	return allMargins, err
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserSegmentMargins(segment string) (Margins, error) {
+	return kiteHttpClient.GetUserSegmentMarginsWithContext(context.Background(), segment)
+}
+
+func (kiteHttpClient *KiteHttpClient) GetUserSegmentMarginsWithContext(ctx context.Context, segment string) (Margins, error) {
+	var margins Margins
+
+	if segment != constants.MarginsEquity && segment != constants.MarginsCommodity {
+		return margins, httpUtils.NewErrorHelper(httpUtils.InputError, fmt.Sprintf("Invalid margins segment `%s`", segment), nil)
+	}
+
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodGet, fmt.Sprintf(constants.URIUserMarginsSegment, segment), nil, nil, &margins)
+	margins.Category = segment
+	return margins, err
+}
+
 func (kiteHttpClient *KiteHttpClient) GenerateSession(requestToken string, apiSecret string) (UserSession, error) {
-	// Get SHA256 checksum
-	h := sha256.New()
-	h.Write([]byte(kiteHttpClient.apiKey + requestToken + apiSecret))
+	return kiteHttpClient.GenerateSessionWithContext(context.Background(), requestToken, apiSecret)
+}
 
+func (kiteHttpClient *KiteHttpClient) GenerateSessionWithContext(ctx context.Context, requestToken string, apiSecret string) (UserSession, error) {
 	// construct url values
 	params := url.Values{}
 	params.Add("api_key", kiteHttpClient.apiKey)
 	params.Add("request_token", requestToken)
-	params.Set("checksum", fmt.Sprintf("%x", h.Sum(nil)))
+	params.Set("checksum", kiteHttpClient.checksum(requestToken, apiSecret))
 
 	var session UserSession
-	err := kiteHttpClient.doEnvelope(http.MethodPost, constants.URIUserSession, params, nil, &session)
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIUserSession, params, nil, &session)
 
 	// Set accessToken on successful session retrieve
-	if err != nil && session.AccessToken != "" {
-		kiteHttpClient.SetEncToken(session.AccessToken)
+	if err == nil && session.AccessToken != "" {
+		kiteHttpClient.SetAccessToken(session.AccessToken)
 	}
 
 	return session, err
 }
+
+func (kiteHttpClient *KiteHttpClient) InvalidateAccessToken() (bool, error) {
+	return kiteHttpClient.InvalidateAccessTokenWithContext(context.Background())
+}
+
+func (kiteHttpClient *KiteHttpClient) InvalidateAccessTokenWithContext(ctx context.Context) (bool, error) {
+	params := url.Values{}
+	params.Add("api_key", kiteHttpClient.apiKey)
+	params.Add("access_token", kiteHttpClient.GetAccessToken())
+
+	var invalidated bool
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, constants.URIUserSessionInvalidate, params, nil, &invalidated)
+	if err == nil {
+		kiteHttpClient.SetAccessToken("")
+	}
+	return invalidated, err
+}
+
+func (kiteHttpClient *KiteHttpClient) RenewAccessToken(refreshToken string, apiSecret string) (UserSessionTokens, error) {
+	return kiteHttpClient.RenewAccessTokenWithContext(context.Background(), refreshToken, apiSecret)
+}
+
+func (kiteHttpClient *KiteHttpClient) RenewAccessTokenWithContext(ctx context.Context, refreshToken string, apiSecret string) (UserSessionTokens, error) {
+	params := url.Values{}
+	params.Add("api_key", kiteHttpClient.apiKey)
+	params.Add("refresh_token", refreshToken)
+	params.Set("checksum", kiteHttpClient.checksum(refreshToken, apiSecret))
+
+	var tokens UserSessionTokens
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodPost, constants.URIUserSessionRenew, params, nil, &tokens)
+
+	// Swap to the renewed access token
+	if err == nil && tokens.AccessToken != "" {
+		kiteHttpClient.SetAccessToken(tokens.AccessToken)
+	}
+
+	return tokens, err
+}
+
+func (kiteHttpClient *KiteHttpClient) InvalidateRefreshToken(refreshToken string) (bool, error) {
+	return kiteHttpClient.InvalidateRefreshTokenWithContext(context.Background(), refreshToken)
+}
+
+func (kiteHttpClient *KiteHttpClient) InvalidateRefreshTokenWithContext(ctx context.Context, refreshToken string) (bool, error) {
+	params := url.Values{}
+	params.Add("api_key", kiteHttpClient.apiKey)
+	params.Add("refresh_token", refreshToken)
+
+	var invalidated bool
+	err := kiteHttpClient.doEnvelope(ctx, http.MethodDelete, constants.URIUserSessionInvalidate, params, nil, &invalidated)
+	return invalidated, err
+}
+
+// checksum is the SHA256 of api_key + token + api_secret that Kite expects
+// when exchanging a request or refresh token.
+func (kiteHttpClient *KiteHttpClient) checksum(token string, apiSecret string) string {
+	h := sha256.New()
+	h.Write([]byte(kiteHttpClient.apiKey + token + apiSecret))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserSessionJSON(t *testing.T) {
+	data := []byte(`{
+		"user_id": "AB1234",
+		"user_name": "Alice",
+		"email": "alice@example.com",
+		"api_key": "key",
+		"public_token": "public",
+		"access_token": "access",
+		"refresh_token": "refresh",
+		"login_time": "2024-01-02 09:15:00"
+	}`)
+
+	var session UserSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.UserID != "AB1234" || session.UserName != "Alice" || session.APIKey != "key" || session.PublicToken != "public" || session.LoginTime.IsZero() {
+		t.Errorf("session = %+v, want the profile and session fields", session)
+	}
+	if want := (UserSessionTokens{UserID: "AB1234", AccessToken: "access", RefreshToken: "refresh"}); session.UserSessionTokens != want {
+		t.Errorf("session tokens = %+v, want %+v", session.UserSessionTokens, want)
+	}
+
+	// Saved sessions are picked up by FileTokenProvider.
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := FileSessionStore(path).Save(session); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := FileTokenProvider(path).Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.AccessToken != "access" {
+		t.Errorf("saved access token = %q, want %q", credentials.AccessToken, "access")
+	}
+
+	var saved UserSession
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.UserID != session.UserID || saved.UserSessionTokens != session.UserSessionTokens || !saved.LoginTime.Equal(session.LoginTime.Time) {
+		t.Errorf("session after a round trip = %+v, want %+v", saved, session)
+	}
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"context"
+	"github.com/algotuners/zerodha-sdk-go/pkg/constants"
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/totp"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+)
+
+const twoFATypeTOTP = "totp"
+
+// WebLoginConfig holds the credentials of a Kite web login. TOTP must be
+// enabled for the user, the code is computed from TOTPSecret.
+type WebLoginConfig struct {
+	UserID   string
+	Password string
+	// TOTPSecret is the base32 secret shown when enabling TOTP.
+	TOTPSecret string
+
+	// BaseURL is the root URL of the Kite web app, constants.KiteBaseURI
+	// when empty.
+	BaseURL string
+	// HTTPClient sends the login requests. The login runs with its own cookie
+	// jar when the client has none.
+	HTTPClient *http.Client
+}
+
+type webLoginResp struct {
+	UserID     string   `json:"user_id"`
+	RequestID  string   `json:"request_id"`
+	TwoFAType  string   `json:"twofa_type"`
+	TwoFATypes []string `json:"twofa_types"`
+}
+
+// WebLogin logs in to Kite web with the user id, password and TOTP and
+// returns a client on BackendWeb authenticated with the session's enctoken.
+// The login is also installed as the client's TokenProvider so an expired
+// session logs in again. The options are applied to the returned client.
+func WebLogin(ctx context.Context, config WebLoginConfig, opts ...Option) (*KiteHttpClient, error) {
+	encToken, err := config.encToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := KiteConnect(append([]Option{WithBackend(BackendWeb), WithEncToken(encToken)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTokenProvider(WebLoginTokenProvider(config))
+	return client, nil
+}
+
+// WebLoginTokenProvider logs in to Kite web for every refresh.
+func WebLoginTokenProvider(config WebLoginConfig) TokenProvider {
+	return TokenProviderFunc(func(ctx context.Context) (Credentials, error) {
+		encToken, err := config.encToken(ctx)
+		return Credentials{EncToken: encToken}, err
+	})
+}
+
+// encToken runs the login and two-factor steps and returns the enctoken
+// cookie set by the latter.
+func (config WebLoginConfig) encToken(ctx context.Context) (string, error) {
+	switch {
+	case config.UserID == "":
+		return "", httpUtils.NewErrorHelper(httpUtils.InputError, "User id is required", nil)
+	case config.Password == "":
+		return "", httpUtils.NewErrorHelper(httpUtils.InputError, "Password is required", nil)
+	}
+	key, err := totp.DecodeSecret(config.TOTPSecret)
+	if err != nil {
+		return "", httpUtils.WrapError(httpUtils.InputError, "Invalid TOTP secret.", 0, err)
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = constants.KiteBaseURI
+	}
+	httpClient := config.httpClient()
+	client := httpUtils.GenerateHttpClient(httpClient, false)
+	headers := http.Header{"X-Kite-Version": {constants.KiteHeaderVersion}}
+
+	var login webLoginResp
+	err = client.DoEnvelope(ctx, http.MethodPost, baseURL+constants.URIWebLogin, url.Values{
+		"user_id":  {config.UserID},
+		"password": {config.Password},
+	}, headers, &login)
+	if err != nil {
+		return "", err
+	}
+	if !login.supportsTOTP() {
+		return "", httpUtils.NewErrorHelper(httpUtils.TwoFAError, "TOTP isn't enabled for the user", nil)
+	}
+
+	resp, err := client.Do(ctx, http.MethodPost, baseURL+constants.URIWebTwoFA, url.Values{
+		"user_id":     {config.UserID},
+		"request_id":  {login.RequestID},
+		"twofa_value": {totp.Code(key, time.Now())},
+		"twofa_type":  {twoFATypeTOTP},
+	}, headers)
+	if err != nil {
+		return "", err
+	}
+	if err := httpUtils.ReadEnvelope(resp, nil); err != nil {
+		return "", err
+	}
+
+	for _, cookie := range resp.Response.Cookies() {
+		if cookie.Name == "enctoken" && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+	if u, err := url.Parse(baseURL); err == nil {
+		for _, cookie := range httpClient.Jar.Cookies(u) {
+			if cookie.Name == "enctoken" && cookie.Value != "" {
+				return cookie.Value, nil
+			}
+		}
+	}
+	return "", httpUtils.NewErrorHelper(httpUtils.TokenError, "Login succeeded without an enctoken cookie", nil)
+}
+
+// httpClient returns the configured client, or a copy of it, with a cookie
+// jar carrying the session cookies from the login to the two-factor step.
+func (config WebLoginConfig) httpClient() *http.Client {
+	var httpClient http.Client
+	if config.HTTPClient != nil {
+		httpClient = *config.HTTPClient
+	} else {
+		httpClient.Timeout = constants.RequestTimeout
+	}
+	if httpClient.Jar == nil {
+		httpClient.Jar, _ = cookiejar.New(nil)
+	}
+	return &httpClient
+}
+
+func (login webLoginResp) supportsTOTP() bool {
+	if login.TwoFAType == twoFATypeTOTP {
+		return true
+	}
+	for _, twoFAType := range login.TwoFATypes {
+		if twoFAType == twoFATypeTOTP {
+			return true
+		}
+	}
+	return false
+}
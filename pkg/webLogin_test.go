@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/algotuners/zerodha-sdk-go/pkg/httpUtils"
+	"github.com/algotuners/zerodha-sdk-go/pkg/totp"
+)
+
+const (
+	testUserID     = "AB1234"
+	testPassword   = "password"
+	testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+)
+
+// newWebLoginServer starts a stand-in for the Kite web login and OMS. Every
+// login sets the enctoken enc-<n>, n counting the logins, unless setCookie is
+// false, and the OMS only accepts the latest.
+func newWebLoginServer(t *testing.T, setCookie bool) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	key, err := totp.DecodeSecret(testTOTPSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeError := func(w http.ResponseWriter, code int, errorType, message string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"status":"error","error_type":%q,"message":%q}`, errorType, message)
+	}
+
+	var logins atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("user_id") != testUserID || r.FormValue("password") != testPassword {
+			writeError(w, http.StatusBadRequest, httpUtils.InputError, "Invalid username or password")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "kf_session", Value: "session", Path: "/"})
+		fmt.Fprintf(w, `{"status":"success","data":{"user_id":%q,"request_id":"request","twofa_type":"totp","twofa_types":["totp"]}}`, testUserID)
+	})
+	mux.HandleFunc("/api/twofa", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("kf_session"); err != nil || cookie.Value != "session" || r.FormValue("request_id") != "request" {
+			writeError(w, http.StatusForbidden, httpUtils.TokenError, "Invalid session")
+			return
+		}
+		// Accept the neighbouring steps as Kite does.
+		now, valid := time.Now(), false
+		for _, at := range []time.Time{now.Add(-totp.Period), now, now.Add(totp.Period)} {
+			valid = valid || r.FormValue("twofa_value") == totp.Code(key, at)
+		}
+		if !valid {
+			writeError(w, http.StatusBadRequest, httpUtils.TwoFAError, "Invalid TOTP")
+			return
+		}
+
+		n := logins.Add(1)
+		if setCookie {
+			http.SetCookie(w, &http.Cookie{Name: "enctoken", Value: fmt.Sprintf("enc-%d", n), Path: "/"})
+		}
+		fmt.Fprint(w, `{"status":"success","data":{}}`)
+	})
+	mux.HandleFunc("/oms/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != fmt.Sprintf("enctoken enc-%d", logins.Load()) {
+			writeError(w, http.StatusForbidden, httpUtils.TokenError, "Incorrect `api_key` or `access_token`.")
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"user_id":%q}}`, testUserID)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &logins
+}
+
+func testWebLoginConfig(server *httptest.Server) WebLoginConfig {
+	return WebLoginConfig{
+		UserID:     testUserID,
+		Password:   testPassword,
+		TOTPSecret: testTOTPSecret,
+		BaseURL:    server.URL,
+	}
+}
+
+func TestWebLogin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		server, _ := newWebLoginServer(t, true)
+		client, err := WebLogin(ctx, testWebLoginConfig(server), WithBaseURL(server.URL+"/oms"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.GetEncToken() != "enc-1" || client.GetBackend() != BackendWeb {
+			t.Errorf("got enctoken %q on %s, want enc-1 on %s", client.GetEncToken(), client.GetBackend(), BackendWeb)
+		}
+		if profile, err := client.GetUserProfile(); err != nil || profile.UserID != testUserID {
+			t.Errorf("GetUserProfile() = %+v, %v", profile, err)
+		}
+	})
+
+	t.Run("bad password", func(t *testing.T) {
+		server, _ := newWebLoginServer(t, true)
+		config := testWebLoginConfig(server)
+		config.Password = "wrong"
+		if _, err := WebLogin(ctx, config); !errors.Is(err, httpUtils.ErrInput) {
+			t.Errorf("WebLogin() error = %v, want ErrInput", err)
+		}
+	})
+
+	t.Run("bad TOTP", func(t *testing.T) {
+		server, _ := newWebLoginServer(t, true)
+		config := testWebLoginConfig(server)
+		config.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		if _, err := WebLogin(ctx, config); !errors.Is(err, httpUtils.ErrTwoFA) {
+			t.Errorf("WebLogin() error = %v, want ErrTwoFA", err)
+		}
+	})
+
+	t.Run("missing enctoken cookie", func(t *testing.T) {
+		server, _ := newWebLoginServer(t, false)
+		if _, err := WebLogin(ctx, testWebLoginConfig(server)); !errors.Is(err, httpUtils.ErrTokenExpired) {
+			t.Errorf("WebLogin() error = %v, want ErrTokenExpired", err)
+		}
+	})
+
+	t.Run("login again on TokenException", func(t *testing.T) {
+		server, logins := newWebLoginServer(t, true)
+		client, err := WebLogin(ctx, testWebLoginConfig(server), WithBaseURL(server.URL+"/oms"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The session expires, e.g. after logging in elsewhere.
+		logins.Add(1)
+		if profile, err := client.GetUserProfile(); err != nil || profile.UserID != testUserID {
+			t.Errorf("GetUserProfile() = %+v, %v", profile, err)
+		}
+		if client.GetEncToken() != "enc-3" || logins.Load() != 3 {
+			t.Errorf("got enctoken %q after %d logins, want enc-3 after 3", client.GetEncToken(), logins.Load())
+		}
+	})
+}